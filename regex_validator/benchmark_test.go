@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Benchmarks for each stage of the import and for the whole import, following the stages profiled in the
+// README (TASK_2_REL to TASK_3_PARA). Each runs on the same generated file so results can be compared
+// between versions with benchstat:
+//     go test -run XXX -bench . -benchmem -count 10 > old.txt
+//     (make changes)
+//     go test -run XXX -bench . -benchmem -count 10 > new.txt
+//     benchstat old.txt new.txt
+
+// the number of rows in the generated file used by the benchmarks
+const BENCHMARK_ROWS int = 100000
+
+// the generated file used by the benchmarks, created once
+var (
+	benchmarkDataOnce sync.Once
+	benchmarkData     []byte
+)
+
+// "benchmarkImportData" returns the contents of a generated import file with BENCHMARK_ROWS rows, the mix of
+// valid & invalid postcodes is the generator's default
+func benchmarkImportData(b *testing.B) []byte {
+	benchmarkDataOnce.Do(func() {
+		var buf bytes.Buffer
+		opts := GeneratorOptions{rows: BENCHMARK_ROWS, seed: GENERATE_SEED_DEFAULT, validRatio: GENERATE_VALID_DEFAULT, shuffle: true, quoting: QUOTING_NONE}
+		check(NewImportDataGenerator(opts).Write(&buf))
+		benchmarkData = buf.Bytes()
+	})
+	return benchmarkData
+}
+
+// "benchmarkImportFile" writes the generated import file to a temporary directory & returns its path
+func benchmarkImportFile(b *testing.B) string {
+	path := filepath.Join(benchmarkTempDir(b), "benchmark.csv")
+	check(ioutil.WriteFile(path, benchmarkImportData(b), 0644))
+	return path
+}
+
+// "readBenchmarkLines_go" reads the import file "path" with readFromInputFiles_go, as the first stage of the import does
+func readBenchmarkLines_go(wg *sync.WaitGroup, path string) <-chan InputLine {
+	return readFromInputFiles_go(wg, newInputFiles([]string{path}), inputFormats[FORMAT_CSV](JsonFieldNames{}), &InputCursor{}, 0, &PipelineError{})
+}
+
+// "benchmarkInputLines" returns the lines of the generated import file as read by readFromInputFiles_go
+func benchmarkInputLines(b *testing.B) []InputLine {
+	var wg sync.WaitGroup
+	var lines []InputLine
+	for line := range readBenchmarkLines_go(&wg, benchmarkImportFile(b)) {
+		lines = append(lines, line)
+	}
+	wg.Wait()
+	return lines
+}
+
+// "benchmarkImportRecords" returns the validated records of the generated import file, split by validity
+func benchmarkImportRecords(b *testing.B) (valid, invalid ImportRecordGroup) {
+	var wg sync.WaitGroup
+	in := make(chan InputLine, CHAN_DEFAULT_SIZE)
+	go func() {
+		for _, line := range benchmarkInputLines(b) {
+			in <- line
+		}
+		close(in)
+	}()
+
+	val := NewRecordValidator(createMainRegexValidatorGroup(), nil, nil)
+	valid, invalid, _ = validateInputRecords(createInputRecords_go(&wg, in, ROW_ID_RANGE_DEFAULT, false, &PipelineError{}), val, false)
+	wg.Wait()
+	return valid, invalid
+}
+
+// "benchmarkTempDir" creates a temporary directory that is removed when the benchmark ends
+func benchmarkTempDir(b *testing.B) string {
+	dir, err := ioutil.TempDir("", "benchmark")
+	check(err)
+	b.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// stage 1: read the file & split each line into its fields
+func Benchmark_readFromInputFiles_go(b *testing.B) {
+	path := benchmarkImportFile(b)
+	b.SetBytes(int64(len(benchmarkImportData(b))))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for range readBenchmarkLines_go(&wg, path) {
+		}
+		wg.Wait()
+	}
+}
+
+// stage 2: create an ImportRecord from the fields of each line
+func Benchmark_createInputRecords_go(b *testing.B) {
+	lines := benchmarkInputLines(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		in := make(chan InputLine, CHAN_DEFAULT_SIZE)
+		go func() {
+			for _, line := range lines {
+				in <- line
+			}
+			close(in)
+		}()
+
+		for range createInputRecords_go(&wg, in, ROW_ID_RANGE_DEFAULT, false, &PipelineError{}) {
+		}
+		wg.Wait()
+	}
+}
+
+// stage 3: validate each record & collect them by validity
+func Benchmark_validateInputRecords(b *testing.B) {
+	valid, invalid := benchmarkImportRecords(b)
+	records := append(append(ImportRecordGroup{}, valid...), invalid...)
+	val := NewRecordValidator(createMainRegexValidatorGroup(), nil, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		in := make(chan *ImportRecord, CHAN_DEFAULT_SIZE)
+		go func() {
+			for _, rec := range records {
+				in <- rec
+			}
+			close(in)
+		}()
+
+		validateInputRecords(in, val, false)
+	}
+}
+
+// stage 4: sort both groups of records by their row id, as the records arrive in the order of the file
+func Benchmark_sortImportRecords(b *testing.B) {
+	valid, invalid := benchmarkImportRecords(b)
+	validCopy := make(ImportRecordGroup, len(valid))
+	invalidCopy := make(ImportRecordGroup, len(invalid))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		copy(validCopy, valid)
+		copy(invalidCopy, invalid)
+		b.StartTimer()
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() { sort.Sort(validCopy); wg.Done() }()
+		go func() { sort.Sort(invalidCopy); wg.Done() }()
+		wg.Wait()
+	}
+}
+
+// stage 5: write the succeeded & failed files
+func Benchmark_writeOutputFiles(b *testing.B) {
+	valid, invalid := benchmarkImportRecords(b)
+	sort.Sort(valid)
+	sort.Sort(invalid)
+	opts := newDefaultProgramOptions("", benchmarkTempDir(b))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		validSink, invalidSink, err := newOutputSinks(opts)
+		check(err)
+		check(writeOutputFiles([]string{"row_id", "postcode"}, valid, invalid, false, validSink, invalidSink, opts))
+	}
+}
+
+// every stage: the whole import as run from the command line with no optional flags
+func Benchmark_runImport(b *testing.B) {
+	dir := benchmarkTempDir(b)
+	path := filepath.Join(dir, "import_data.csv")
+	data := benchmarkImportData(b)
+	check(ioutil.WriteFile(path, data, 0644))
+
+	opts := newDefaultProgramOptions(path, dir)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := runImport(time.Now(), opts)
+		check(err)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// the directory inside the output directory that checkpoints are written to & the files inside it
+const (
+	CHECKPOINT_DIR_NAME        = ".checkpoint"
+	CHECKPOINT_STATE_FILE_NAME = "checkpoint.json"
+	CHECKPOINT_PART_FILE_NAME  = "part_%04d.gob"
+)
+
+// type that stores how far through the input files reading has got: the input file being read, the byte offset
+// of the first line not yet read & the number of the last line read (the header is line 1)
+type InputCursor struct {
+	FileIndex  int
+	Offset     int64
+	LineNumber uint64
+	Malformed  int // the number of malformed rows skipped before the cursor (-malformed-rows skip)
+}
+
+// "done" reports whether every input file has been read
+func (c InputCursor) done(inputs []*InputFile) bool {
+	return c.FileIndex >= len(inputs)
+}
+
+// type that stores an input file as it was when a checkpoint was written, to check it is unchanged on resume
+type CheckpointInput struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// type that stores everything needed to resume a run: the input files, the options that change the result of
+// validation, how often checkpoints are written, where reading got to & the part files holding the records
+// validated so far
+type CheckpointState struct {
+	Inputs  []CheckpointInput
+	Options string
+	Every   int
+	Cursor  InputCursor
+	Parts   []string
+}
+
+// type that stores a validated record in a part file, the postcode components are not stored as they are worked
+// out again from the postcode
+type SpilledRecord struct {
+	RowId           uint64
+	Source          int
+	LineNumber      uint64
+	Postcode        string
+	IsValid         bool
+	Class           PostcodeClass
+	DirectoryStatus DirectoryStatus
+	Terminated      string
+	Reason          string
+	Suggestion      string
+	Confidence      float64
+}
+
+// "checkpointOptions" returns the options that change how records are validated, a run can only be resumed
+// with the same options
+func checkpointOptions(opts *ProgramOptions) string {
+	return fmt.Sprintf("profile=%s directory=%s suggest=%t row-ids=%d-%d input-format=%s encoding=%s blank-lines=%s malformed-rows=%s json-fields=%s,%s",
+		opts.profile, opts.directoryPath, opts.showSuggestions, opts.rowIdRange.min, opts.rowIdRange.max,
+		opts.inputFormat, opts.encoding, opts.blankLines, opts.malformedRows, opts.jsonFields.id, opts.jsonFields.postcode)
+}
+
+// "newCheckpointState" creates the state of a run that has not read anything yet
+func newCheckpointState(inputs []*InputFile, opts *ProgramOptions, every int) (*CheckpointState, error) {
+	state := &CheckpointState{Options: checkpointOptions(opts), Every: every}
+
+	for _, input := range inputs {
+		info, err := os.Stat(input.path)
+		if err != nil {
+			return nil, &InputError{path: input.path, err: err}
+		}
+		state.Inputs = append(state.Inputs, CheckpointInput{Path: input.path, Size: info.Size(), ModTime: info.ModTime().UTC()})
+	}
+	return state, nil
+}
+
+// "matches" returns an error if "saved" was not written by a run of the same input files (unchanged) & options
+func (state *CheckpointState) matches(saved *CheckpointState) error {
+	if !reflect.DeepEqual(state.Inputs, saved.Inputs) {
+		return errors.New("the input files are not the ones the checkpoint was written for, or have changed since")
+	}
+	if state.Options != saved.Options {
+		return fmt.Errorf("the checkpoint was written with other options (%s)", saved.Options)
+	}
+	return nil
+}
+
+// "loadCheckpointState" reads the state saved in the checkpoint directory "dir", nil is returned if there is none
+func loadCheckpointState(dir string) (*CheckpointState, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, CHECKPOINT_STATE_FILE_NAME))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &CheckpointState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// "saveCheckpointState" writes "state" to the checkpoint directory "dir". It is written to a temporary file
+// which is then renamed, so a crash while writing leaves the previous checkpoint in place
+func saveCheckpointState(dir string, state *CheckpointState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, CHECKPOINT_STATE_FILE_NAME)
+	if err := writeFileSynced(path+".tmp", func(file *os.File) error {
+		_, err := file.Write(data)
+		return err
+	}); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// "writeCheckpointPart" writes the records of "groups" to the part file "path"
+func writeCheckpointPart(path string, groups ...ImportRecordGroup) error {
+	var spilled []SpilledRecord
+	for _, group := range groups {
+		for _, rec := range group {
+			spilled = append(spilled, SpilledRecord{
+				RowId: rec.rowId, Source: rec.location().sourceIndex(), LineNumber: rec.lineNumber, Postcode: rec.postcode,
+				IsValid: rec.isValid, Class: rec.class, DirectoryStatus: rec.directoryStatus, Terminated: rec.terminated,
+				Reason: rec.reason, Suggestion: rec.suggestion, Confidence: rec.confidence,
+			})
+		}
+	}
+
+	return writeFileSynced(path, func(file *os.File) error {
+		return gob.NewEncoder(file).Encode(spilled)
+	})
+}
+
+// "readCheckpointPart" reads the records in the part file "path", split by validity
+func readCheckpointPart(path string, inputs []*InputFile) (valid, invalid ImportRecordGroup, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	var spilled []SpilledRecord
+	if err := gob.NewDecoder(file).Decode(&spilled); err != nil {
+		return nil, nil, err
+	}
+
+	for _, s := range spilled {
+		if s.Source < 0 || s.Source >= len(inputs) {
+			return nil, nil, fmt.Errorf("the part file \"%s\" refers to input file %d", path, s.Source)
+		}
+
+		rec := &ImportRecord{
+			rowId: s.RowId, source: inputs[s.Source], lineNumber: s.LineNumber, postcode: s.Postcode, isValid: s.IsValid,
+			class: s.Class, directoryStatus: s.DirectoryStatus, terminated: s.Terminated, reason: s.Reason,
+			suggestion: s.Suggestion, confidence: s.Confidence,
+		}
+		if rec.isValid {
+			rec.components, _ = parsePostcodeComponents(rec.postcode)
+			valid = append(valid, rec)
+		} else {
+			invalid = append(invalid, rec)
+		}
+	}
+	return valid, invalid, nil
+}
+
+// "writeFileSynced" creates the file "path", calls "write" to fill it & makes sure it is on disk before closing it
+func writeFileSynced(path string, write func(file *os.File) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// type that writes the checkpoints of a run to the checkpoint directory "dir" & holds the state last written.
+// "afterSave" is called each time a checkpoint has been written if it is set, an error it returns stops the run
+type Checkpointer struct {
+	dir       string
+	state     *CheckpointState
+	afterSave func(state *CheckpointState) error
+}
+
+// "newCheckpointer" returns a Checkpointer for the run described by "opts", nil if checkpoints are not turned on
+// & there is no checkpoint to resume from. When resuming the state is the one saved by the last run, an error
+// is returned if that run was of other input files or options
+func newCheckpointer(inputs []*InputFile, opts *ProgramOptions) (*Checkpointer, error) {
+	if opts.checkpointEvery == 0 && !opts.resume {
+		return nil, nil
+	}
+
+	dir := opts.outputPath(CHECKPOINT_DIR_NAME)
+	state, err := newCheckpointState(inputs, opts, opts.checkpointEvery)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.resume {
+		saved, err := loadCheckpointState(dir)
+		if err != nil {
+			return nil, &InputError{path: dir, err: err}
+		}
+		if saved != nil {
+			if err := state.matches(saved); err != nil {
+				return nil, fmt.Errorf("Can not resume from the checkpoint in \"%s\": %s", dir, err)
+			}
+			// keep writing checkpoints as often as before unless told otherwise
+			if state.Every == 0 {
+				state.Every = saved.Every
+			}
+			state.Cursor = saved.Cursor
+			state.Parts = saved.Parts
+		}
+	}
+
+	if state.Every == 0 {
+		return nil, nil
+	}
+
+	// a run that is not resumed starts a new checkpoint, so remove any old one
+	if len(state.Parts) == 0 {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, &OutputError{path: dir, err: err}
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, &OutputError{path: dir, err: err}
+	}
+	return &Checkpointer{dir: dir, state: state, afterSave: opts.afterCheckpoint}, nil
+}
+
+// "load" reads the records of every part file written so far
+func (c *Checkpointer) load(inputs []*InputFile) (valid, invalid ImportRecordGroup, err error) {
+	for _, part := range c.state.Parts {
+		partValid, partInvalid, err := readCheckpointPart(filepath.Join(c.dir, part), inputs)
+		if err != nil {
+			return nil, nil, &InputError{path: filepath.Join(c.dir, part), err: err}
+		}
+		valid = append(valid, partValid...)
+		invalid = append(invalid, partInvalid...)
+	}
+	return valid, invalid, nil
+}
+
+// "save" writes the records validated since the last checkpoint to a new part file, then saves the state with
+// reading at "cursor". The state is only saved once the part file is on disk, so a crash at any point leaves a
+// checkpoint that can be resumed from
+func (c *Checkpointer) save(cursor InputCursor, valid, invalid ImportRecordGroup) error {
+	part := fmt.Sprintf(CHECKPOINT_PART_FILE_NAME, len(c.state.Parts)+1)
+	if err := writeCheckpointPart(filepath.Join(c.dir, part), valid, invalid); err != nil {
+		return &OutputError{path: filepath.Join(c.dir, part), err: err}
+	}
+
+	c.state.Cursor = cursor
+	c.state.Parts = append(c.state.Parts, part)
+	if err := saveCheckpointState(c.dir, c.state); err != nil {
+		return &OutputError{path: filepath.Join(c.dir, CHECKPOINT_STATE_FILE_NAME), err: err}
+	}
+	if c.afterSave != nil {
+		return c.afterSave(c.state)
+	}
+	return nil
+}
+
+// "remove" removes the checkpoint directory, once the run is complete
+func (c *Checkpointer) remove() error {
+	return os.RemoveAll(c.dir)
+}
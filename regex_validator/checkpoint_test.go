@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// expected: the records written to a part file are read back as they were, with the components of valid postcodes
+func Test_readCheckpointPart__RoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	check(err)
+	defer os.RemoveAll(dir)
+
+	inputs := newInputFiles([]string{"a.csv", "b.csv"})
+	components, _ := parsePostcodeComponents("EC1A 1BB")
+	valid := ImportRecordGroup{{rowId: 1, source: inputs[1], lineNumber: 2, postcode: "EC1A 1BB", isValid: true, components: components}}
+	invalid := ImportRecordGroup{{rowId: 2, source: inputs[0], lineNumber: 3, postcode: "LS44PL", reason: REASON_NO_SPACE, suggestion: "LS4 4PL", confidence: 0.9}}
+
+	path := filepath.Join(dir, fmt.Sprintf(CHECKPOINT_PART_FILE_NAME, 1))
+	check(writeCheckpointPart(path, valid, invalid))
+	resultValid, resultInvalid, err := readCheckpointPart(path, inputs)
+
+	if err != nil || !reflect.DeepEqual(resultValid, valid) || !reflect.DeepEqual(resultInvalid, invalid) {
+		error := fmt.Sprintf("Expected: %v & %v   got: %v & %v (error: %v)", valid, invalid, resultValid, resultInvalid, err)
+		t.Error(error)
+	}
+}
+
+// expected: a checkpoint can only be resumed with the same, unchanged input files & the same options
+func Test_CheckpointState__Matches(t *testing.T) {
+	path, dir := writeTempInput(t, "row_id,postcode\n1,EC1A 1BB\n")
+	defer os.RemoveAll(dir)
+
+	opts := newDefaultProgramOptions(path, dir)
+	saved, err := newCheckpointState(newInputFiles(opts.paths), opts, 10)
+	check(err)
+
+	state, _ := newCheckpointState(newInputFiles(opts.paths), opts, 10)
+	if err := state.matches(saved); err != nil {
+		error := fmt.Sprintf("Given the same run, Expected: no error   got: %v", err)
+		t.Error(error)
+	}
+
+	opts.showSuggestions = true
+	state, _ = newCheckpointState(newInputFiles(opts.paths), opts, 10)
+	if err := state.matches(saved); err == nil {
+		t.Error("Given -suggest turned on, Expected: an error   got: nil")
+	}
+
+	opts.showSuggestions = false
+	check(ioutil.WriteFile(path, []byte("row_id,postcode\n1,EC1A 1BB\n2,M1 1AE\n"), 0644))
+	state, _ = newCheckpointState(newInputFiles(opts.paths), opts, 10)
+	if err := state.matches(saved); err == nil {
+		t.Error("Given the input file has changed, Expected: an error   got: nil")
+	}
+}
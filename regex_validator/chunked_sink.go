@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// the name of each chunk file is the output file's name followed by its number (from 1) & then its extension, e.g.
+// "succeeded_validation.0001.csv", the manifest of the chunks is written alongside them
+const (
+	CHUNK_NAME_FORMAT        = "%s.%04d"
+	CHUNK_MANIFEST_EXTENSION = ".manifest.json"
+)
+
+// type that stores a chunk file in the manifest: its name, the number of records in it & the row ids of the first
+// & last (the output is sorted by row id so these are the range of its row ids), its size & SHA-256 checksum
+type ChunkInfo struct {
+	File       string `json:"file"`
+	Rows       int    `json:"rows"`
+	FirstRowId uint64 `json:"first_row_id"`
+	LastRowId  uint64 `json:"last_row_id"`
+	Bytes      int64  `json:"bytes"`
+	Sha256     string `json:"sha256"`
+}
+
+// type that stores the manifest of a chunked output file: the columns each chunk holds (each chunk has a header
+// row when the format has one) & each chunk in order
+type ChunkManifest struct {
+	Columns []string    `json:"columns"`
+	Chunks  []ChunkInfo `json:"chunks"`
+}
+
+// type of RecordSink that splits the records it is sent over numbered files created by "create", starting a new
+// file before one would hold more than "maxRows" records or "maxBytes" bytes of text (if they are not 0). A
+// file always holds at least one record. The manifest is written to "manifestPath" when the sink is closed, the
+// first value of each record must be its row id
+type ChunkedSink struct {
+	create       func(index int) *FileSink
+	maxRows      int
+	maxBytes     int64
+	manifestPath string
+	current      *FileSink
+	record       bytes.Buffer
+	manifest     ChunkManifest
+	chunks       []*FileSink
+	err          error
+}
+
+// "NewChunkedSink" creates a ChunkedSink, "create" is given the number of each chunk (from 1)
+func NewChunkedSink(create func(index int) *FileSink, maxRows int, maxBytes int64, manifestPath string) *ChunkedSink {
+	return &ChunkedSink{create: create, maxRows: maxRows, maxBytes: maxBytes, manifestPath: manifestPath}
+}
+
+// "Open" opens the first chunk, so there is one even if no record is written
+func (sink *ChunkedSink) Open(columns []string) error {
+	sink.manifest = ChunkManifest{Columns: columns, Chunks: []ChunkInfo{}}
+	return sink.nextChunk()
+}
+
+// "Write" writes the record to the current chunk, or to a new one if it would go over either limit
+func (sink *ChunkedSink) Write(values []interface{}) error {
+	if sink.err != nil {
+		return sink.err
+	}
+
+	sink.record.Reset()
+	if err := sink.current.format.writeRecord(&sink.record, sink.manifest.Columns, values); err != nil {
+		return sink.fail(err)
+	}
+
+	info := &sink.manifest.Chunks[len(sink.manifest.Chunks)-1]
+	full := sink.maxRows > 0 && info.Rows == sink.maxRows
+	tooBig := sink.maxBytes > 0 && sink.current.textSize()+int64(sink.record.Len()) > sink.maxBytes
+	if info.Rows > 0 && (full || tooBig) {
+		if err := sink.nextChunk(); err != nil {
+			return err
+		}
+		info = &sink.manifest.Chunks[len(sink.manifest.Chunks)-1]
+	}
+
+	rowId, _ := values[0].(uint64)
+	if info.Rows == 0 {
+		info.FirstRowId = rowId
+	}
+	info.LastRowId = rowId
+	info.Rows++
+	return sink.fail(sink.current.writeEncoded(sink.record.Bytes()))
+}
+
+// "Close" closes the last chunk & writes the manifest
+func (sink *ChunkedSink) Close() error {
+	if sink.current == nil {
+		return sink.err
+	}
+	sink.fail(sink.closeChunk())
+	sink.current = nil
+
+	if sink.err == nil {
+		data, err := json.MarshalIndent(sink.manifest, "", "  ")
+		if err == nil {
+			err = writeFileSynced(sink.manifestPath, func(file *os.File) error {
+				_, err := file.Write(append(data, '\n'))
+				return err
+			})
+		}
+		if err != nil {
+			sink.err = &OutputError{path: sink.manifestPath, err: err}
+		}
+	}
+	return sink.err
+}
+
+// "nextChunk" closes the current chunk (if there is one) & opens the next
+func (sink *ChunkedSink) nextChunk() error {
+	if sink.current != nil {
+		if err := sink.closeChunk(); err != nil {
+			return sink.fail(err)
+		}
+	}
+
+	sink.current = sink.create(len(sink.manifest.Chunks) + 1)
+	sink.current.checksum = sha256.New()
+	sink.chunks = append(sink.chunks, sink.current)
+	sink.manifest.Chunks = append(sink.manifest.Chunks, ChunkInfo{File: filepath.Base(sink.current.path)})
+	return sink.fail(sink.current.Open(sink.manifest.Columns))
+}
+
+// "closeChunk" closes the current chunk & adds its size & checksum to the manifest
+func (sink *ChunkedSink) closeChunk() error {
+	if err := sink.current.Close(); err != nil {
+		return err
+	}
+	info := &sink.manifest.Chunks[len(sink.manifest.Chunks)-1]
+	_, info.Bytes = sink.current.sizes()
+	info.Sha256 = hex.EncodeToString(sink.current.checksum.Sum(nil))
+	return nil
+}
+
+// "fail" keeps the first error the sink has & returns it, errors of the chunks are already OutputErrors
+func (sink *ChunkedSink) fail(err error) error {
+	if err != nil && sink.err == nil {
+		if _, ok := err.(*OutputError); !ok && sink.current != nil {
+			err = &OutputError{path: sink.current.path, err: err}
+		}
+		sink.err = err
+	}
+	return sink.err
+}
+
+// "fileSinks" returns the files "sink" wrote to: itself if it is a FileSink, its chunks if it is a ChunkedSink
+func fileSinks(sink RecordSink) []*FileSink {
+	switch s := sink.(type) {
+	case *FileSink:
+		return []*FileSink{s}
+	case *ChunkedSink:
+		return s.chunks
+	}
+	return nil
+}
+
+// "chunkedSinkName" returns the name the sink for chunk "index" of the output file "name" is created with
+func chunkedSinkName(name string, index int) string {
+	return fmt.Sprintf(CHUNK_NAME_FORMAT, name, index)
+}
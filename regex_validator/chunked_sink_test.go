@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// "readManifest" reads the chunk manifest "path"
+func readManifest(t *testing.T, path string) ChunkManifest {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest ChunkManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	return manifest
+}
+
+// expected: records are split over chunks of no more than the maximum rows, each with the header, & the manifest
+// lists each chunk's rows, row id range, size & checksum
+func Test_runImport__MaxRowsPerFile(t *testing.T) {
+	path, dir := writeTempInput(t, "row_id,postcode\n5,EC1A 1BB\n3,M1 1AE\n9,LS44PL\n1,B33 8TH\n7,CR2 6XH\n")
+	defer os.RemoveAll(dir)
+
+	opts := newDefaultProgramOptions(path, dir)
+	opts.maxRowsPerFile = 2
+	if _, err := runImport(time.Now(), opts); err != nil {
+		error := fmt.Sprintf("Expected: no error   got: %v", err)
+		t.Fatal(error)
+	}
+
+	expected := map[string]string{
+		"succeeded_validation.0001.csv": "row_id,postcode\n1,B33 8TH\n3,M1 1AE\n",
+		"succeeded_validation.0002.csv": "row_id,postcode\n5,EC1A 1BB\n7,CR2 6XH\n",
+		"failed_validation.0001.csv":    "row_id,postcode\n9,LS44PL\n",
+	}
+	for name, contents := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != contents {
+			error := fmt.Sprintf("Given file: %s, Expected: %q   got: %q (error: %v)", name, contents, data, err)
+			t.Error(error)
+		}
+	}
+
+	manifest := readManifest(t, filepath.Join(dir, "succeeded_validation"+CHUNK_MANIFEST_EXTENSION))
+	if len(manifest.Chunks) != 2 || !reflect.DeepEqual(manifest.Columns, []string{"row_id", "postcode"}) {
+		error := fmt.Sprintf("Expected: 2 chunks of row_id,postcode   got: %+v", manifest)
+		t.Fatal(error)
+	}
+	for i, element := range []struct{ first, last uint64 }{{1, 3}, {5, 7}} {
+		chunk := manifest.Chunks[i]
+		sum := sha256.Sum256([]byte(expected[chunk.File]))
+		if chunk.Rows != 2 || chunk.FirstRowId != element.first || chunk.LastRowId != element.last ||
+			chunk.Bytes != int64(len(expected[chunk.File])) || chunk.Sha256 != hex.EncodeToString(sum[:]) {
+			error := fmt.Sprintf("Given chunk: %d, Expected: rows %d to %d of %s   got: %+v", i+1, element.first, element.last, chunk.File, chunk)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: a chunk is never more than the maximum bytes unless it holds a single record bigger than that, & an
+// empty stream still has one chunk holding the header
+func Test_ChunkedSink__MaxBytes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chunks")
+	check(err)
+	defer os.RemoveAll(dir)
+
+	sink := NewChunkedSink(func(index int) *FileSink {
+		return NewCsvSink(filepath.Join(dir, chunkedSinkName("out", index)+".csv"))
+	}, 0, 40, filepath.Join(dir, "out"+CHUNK_MANIFEST_EXTENSION))
+
+	// the header is 16 bytes & each record 11 bytes, so 2 records fit in 40 bytes
+	recs := make([]*ImportRecord, 5)
+	for i := range recs {
+		recs[i] = &ImportRecord{rowId: uint64(i + 1), postcode: "EC1A 1BB"}
+	}
+	values := func(rec *ImportRecord) []interface{} { return []interface{}{rec.rowId, rec.postcode} }
+	check(writeRecords(sink, []string{"row_id", "postcode"}, recs, values))
+
+	manifest := readManifest(t, filepath.Join(dir, "out"+CHUNK_MANIFEST_EXTENSION))
+	var rows []int
+	for _, chunk := range manifest.Chunks {
+		rows = append(rows, chunk.Rows)
+		if chunk.Bytes > 40 {
+			error := fmt.Sprintf("Given a maximum of 40 bytes, Expected: no bigger chunk   got: %+v", chunk)
+			t.Error(error)
+		}
+	}
+	if !reflect.DeepEqual(rows, []int{2, 2, 1}) {
+		error := fmt.Sprintf("Given 5 records, Expected: chunks of 2, 2 & 1   got: %v", rows)
+		t.Error(error)
+	}
+
+	empty := NewChunkedSink(func(index int) *FileSink {
+		return NewCsvSink(filepath.Join(dir, chunkedSinkName("empty", index)+".csv"))
+	}, 0, 40, filepath.Join(dir, "empty"+CHUNK_MANIFEST_EXTENSION))
+	check(writeRecords(empty, []string{"row_id", "postcode"}, nil, values))
+
+	if manifest := readManifest(t, filepath.Join(dir, "empty"+CHUNK_MANIFEST_EXTENSION)); len(manifest.Chunks) != 1 || manifest.Chunks[0].Rows != 0 {
+		error := fmt.Sprintf("Given no records, Expected: one empty chunk   got: %+v", manifest.Chunks)
+		t.Error(error)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// the values -compress can be given & the size of the blocks the parallel gzip writer compresses
+const (
+	COMPRESS_NONE = "none"
+	COMPRESS_GZIP = "gzip"
+	COMPRESS_ZSTD = "zstd"
+
+	GZIP_BLOCK_SIZE = 1 << 20
+)
+
+// "checkCompression" returns an error if "name" is not a compression the program can write
+func checkCompression(name string) error {
+	switch name {
+	case COMPRESS_NONE, COMPRESS_GZIP:
+		return nil
+	case COMPRESS_ZSTD:
+		return fmt.Errorf("-compress %s is not available, the program only uses the Go standard library which has no zstd encoder", COMPRESS_ZSTD)
+	}
+	return fmt.Errorf("unknown compression \"%s\", must be one of: %s", name, strings.Join([]string{COMPRESS_NONE, COMPRESS_GZIP}, ", "))
+}
+
+// type of writer that counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// type of writer that gzips what is written to it using every CPU. The text is cut into blocks of "blockSize"
+// bytes which are compressed at the same time, each as its own gzip member, & written to "out" in order. gzip
+// readers (including gunzip & Go's gzip.Reader) read the members back as one stream
+type ParallelGzipWriter struct {
+	out       io.Writer
+	blockSize int
+	block     []byte
+	queue     chan chan []byte
+	done      chan struct{}
+	numBlocks int
+	mutex     sync.Mutex // guards "err", which is set by the go routine writing to "out"
+	err       error
+}
+
+// "NewParallelGzipWriter" creates a ParallelGzipWriter writing to "out", a block is compressed by each CPU at a time
+func NewParallelGzipWriter(out io.Writer) *ParallelGzipWriter {
+	w := &ParallelGzipWriter{
+		out:       out,
+		blockSize: GZIP_BLOCK_SIZE,
+		queue:     make(chan chan []byte, runtime.GOMAXPROCS(0)),
+		done:      make(chan struct{}),
+	}
+
+	// the compressed blocks are written in the order they were queued, the queue being full holds back "Write"
+	// so no more than one block per CPU is waiting to be written
+	go func() {
+		for result := range w.queue {
+			data := <-result
+			if w.writeErr() == nil {
+				if _, err := w.out.Write(data); err != nil {
+					w.mutex.Lock()
+					w.err = err
+					w.mutex.Unlock()
+				}
+			}
+		}
+		close(w.done)
+	}()
+	return w
+}
+
+// "Write" adds "p" to the current block, compressing each block that is filled. Once writing to "out" has failed
+// the error is returned & nothing more is added, along with the number of bytes of "p" added before it was seen
+func (w *ParallelGzipWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if err := w.writeErr(); err != nil {
+			return written, err
+		}
+
+		n := w.blockSize - len(w.block)
+		if n > len(p) {
+			n = len(p)
+		}
+		w.block = append(w.block, p[:n]...)
+		p = p[n:]
+		written += n
+
+		if len(w.block) == w.blockSize {
+			w.compressBlock()
+		}
+	}
+	return written, w.writeErr()
+}
+
+// "Close" compresses the last block & waits for every block to be written, the first error writing to "out" is
+// returned. An empty gzip member is written if nothing was, so the output is still a gzip file
+func (w *ParallelGzipWriter) Close() error {
+	if len(w.block) > 0 || w.numBlocks == 0 {
+		w.compressBlock()
+	}
+	close(w.queue)
+	<-w.done
+	return w.writeErr()
+}
+
+// "writeErr" returns the first error writing to "out", nil if there has been none yet
+func (w *ParallelGzipWriter) writeErr() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.err
+}
+
+// "compressBlock" compresses the current block in its own go routine & starts a new block
+func (w *ParallelGzipWriter) compressBlock() {
+	result := make(chan []byte, 1)
+	w.queue <- result
+	w.numBlocks++
+
+	go func(block []byte) {
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		zw.Write(block)
+		zw.Close()
+		result <- compressed.Bytes()
+	}(w.block)
+
+	w.block = make([]byte, 0, w.blockSize)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// "gunzip" returns the text of the gzip data "data", reading every gzip member
+func gunzip(t *testing.T, data []byte) string {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(text)
+}
+
+// expected: the text is read back as it was written, whether it fills no block, part of one or several
+func Test_ParallelGzipWriter__RoundTrip(t *testing.T) {
+	for _, text := range []string{"", "row_id,postcode\n", strings.Repeat("1,EC1A 1BB\n", 1000)} {
+		var out bytes.Buffer
+		w := NewParallelGzipWriter(&out)
+		w.blockSize = 64
+
+		// write in pieces that do not line up with the blocks
+		for i := 0; i < len(text); i += 100 {
+			end := i + 100
+			if end > len(text) {
+				end = len(text)
+			}
+			w.Write([]byte(text[i:end]))
+		}
+		check(w.Close())
+
+		if result := gunzip(t, out.Bytes()); result != text {
+			error := fmt.Sprintf("Given %d bytes of text, Expected: the same text   got: %d bytes", len(text), len(result))
+			t.Error(error)
+		}
+	}
+}
+
+// type of writer that fails every write, as a full disk would
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+// expected: "Write" returns the error writing to "out" before "Close" is called, & "Close" returns it too
+func Test_ParallelGzipWriter__WriteError(t *testing.T) {
+	w := NewParallelGzipWriter(failingWriter{})
+	w.blockSize = 4
+
+	// once more blocks have been queued than the queue holds the first block has been written, so the error
+	// must have been seen by then
+	var err error
+	for i := 0; i < cap(w.queue)+3 && err == nil; i++ {
+		_, err = w.Write([]byte("1234"))
+	}
+	if err == nil {
+		error := fmt.Sprintf("Given an output that fails every write, Expected: an error from Write   got: %v", err)
+		t.Error(error)
+	}
+
+	if closeErr := w.Close(); closeErr == nil || closeErr.Error() != "no space left on device" {
+		error := fmt.Sprintf("Given an output that fails every write, Expected: the write error from Close   got: %v", closeErr)
+		t.Error(error)
+	}
+}
+
+// expected: gzip & none are accepted, zstd is explained & anything else is an error
+func Test_checkCompression(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected bool
+	}{
+		{COMPRESS_NONE, true},
+		{COMPRESS_GZIP, true},
+		{COMPRESS_ZSTD, false},
+		{"bzip2", false},
+	}
+
+	for _, element := range testCases {
+		if err := checkCompression(element.name); (err == nil) != element.expected {
+			error := fmt.Sprintf("Given compression: %s, Expected accepted: %t   got: %v", element.name, element.expected, err)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: -compress gzip writes both output files gzipped, holding the text of an uncompressed run, & counts
+// the size of each before & after compression
+func Test_runImport__CompressGzip(t *testing.T) {
+	path, dir := writeTempInput(t, "row_id,postcode\n2,LS44PL\n1,EC1A 1BB\n")
+	defer os.RemoveAll(dir)
+
+	opts := newDefaultProgramOptions(path, dir)
+	opts.compress = COMPRESS_GZIP
+	if _, err := runImport(time.Now(), opts); err != nil {
+		error := fmt.Sprintf("Expected: no error   got: %v", err)
+		t.Fatal(error)
+	}
+
+	expected := map[string]string{
+		"succeeded_validation.csv.gz": "row_id,postcode\n1,EC1A 1BB\n",
+		"failed_validation.csv.gz":    "row_id,postcode\n2,LS44PL\n",
+	}
+	for name, contents := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		check(err)
+		if result := gunzip(t, data); result != contents {
+			error := fmt.Sprintf("Given file: %s, Expected: %q   got: %q", name, contents, result)
+			t.Error(error)
+		}
+	}
+
+	valid, _, err := newOutputSinks(opts)
+	check(err)
+	check(writeRecords(valid, []string{"row_id", "postcode"}, nil, nil))
+	if text, compressed := valid.(*FileSink).sizes(); text != int64(len("row_id,postcode\n")) || compressed == 0 {
+		error := fmt.Sprintf("Given a header only, Expected: %d bytes of text & a compressed size   got: %d & %d", len("row_id,postcode\n"), text, compressed)
+		t.Error(error)
+	}
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the name of the subcommand that compares two validation runs & the file it writes the changes to by default
+const (
+	DIFF_COMMAND     = "diff"
+	DIFF_OUT_DEFAULT = "validation_diff.csv"
+)
+
+// the kinds of change between two runs written to the diff file, one line is written for each change so a row
+// that became valid with another postcode is written twice
+const (
+	CHANGE_ADDED          = "added"            // the row id is only in the new run
+	CHANGE_REMOVED        = "removed"          // the row id is only in the old run
+	CHANGE_BECAME_VALID   = "became_valid"     // the row was invalid in the old run & is valid in the new one
+	CHANGE_BECAME_INVALID = "became_invalid"   // the row was valid in the old run & is invalid in the new one
+	CHANGE_POSTCODE       = "postcode_changed" // the row's postcode is not the same in both runs
+)
+
+// the columns of the diff file
+var DIFF_COLUMNS = []string{"row_id", "change", "old_postcode", "new_postcode", "old_valid", "new_valid"}
+
+// type that stores the options of the diff subcommand, the runs are either read from two output directories or
+// made by validating two input files, each with its own rule profile
+type DiffOptions struct {
+	oldDir     string
+	newDir     string
+	oldInput   string
+	newInput   string
+	oldProfile string
+	newProfile string
+	out        string
+}
+
+// type that stores the number of rows of each kind of change found by "diffRuns"
+type DiffSummary struct {
+	added          int
+	removed        int
+	becameValid    int
+	becameInvalid  int
+	postcodeChange int
+	unchanged      int
+}
+
+// type that stores a row of the output of a run: its row id, postcode & whether it is in succeeded_validation.csv
+type OutputRow struct {
+	rowId    uint64
+	postcode string
+	isValid  bool
+}
+
+// type that reads the rows of one output file of a run in order, checking they are sorted by row id. An output
+// file split into chunks (-max-rows-per-file, -max-bytes-per-file) is read one chunk after another as if it were
+// one file, files ending in ".gz" (-compress gzip) are decompressed as they are read
+type OutputFileReader struct {
+	paths   []string
+	path    string
+	file    *os.File
+	reader  *csv.Reader
+	isValid bool
+	line    int
+	rows    int
+	last    uint64
+}
+
+// "NewOutputFileReader" opens the first of "paths", the files holding an output file of a run in order
+// (succeeded_validation.csv if "isValid" is set, otherwise failed_validation.csv), & skips its header
+func NewOutputFileReader(paths []string, isValid bool) (*OutputFileReader, error) {
+	r := &OutputFileReader{paths: paths, isValid: isValid}
+	if err := r.openNext(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// "outputFilePaths" returns the files holding the output file "name" (e.g. succeeded_validation.csv) of the run in
+// the output directory "dir": the chunks listed in its manifest if it was split, otherwise the file itself or
+// its gzip file. The file itself is returned if none of them are there, so opening it reports it missing
+func outputFilePaths(dir, name string) ([]string, error) {
+	manifestPath := filepath.Join(dir, withExtension(name, CHUNK_MANIFEST_EXTENSION))
+	data, err := ioutil.ReadFile(manifestPath)
+	if err == nil {
+		var manifest ChunkManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, &ParseError{path: manifestPath, lineNumber: 1, err: err}
+		}
+		if len(manifest.Chunks) == 0 {
+			return nil, &ParseError{path: manifestPath, lineNumber: 1, err: errors.New("the manifest lists no chunk files")}
+		}
+		paths := make([]string, len(manifest.Chunks))
+		for i, chunk := range manifest.Chunks {
+			paths[i] = filepath.Join(dir, chunk.File)
+		}
+		return paths, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, &InputError{path: manifestPath, err: err}
+	}
+
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err := os.Stat(path + ".gz"); err == nil {
+			return []string{path + ".gz"}, nil
+		}
+	}
+	return []string{path}, nil
+}
+
+// "openNext" closes the file being read (if there is one) & opens the next of "paths", skipping its header
+func (r *OutputFileReader) openNext() error {
+	r.Close()
+	r.path, r.line = r.paths[0], 1
+	r.paths = r.paths[1:]
+
+	file, err := os.Open(r.path)
+	if err != nil {
+		return &InputError{path: r.path, err: err}
+	}
+	r.file = file
+
+	var text io.Reader = bufio.NewReader(file)
+	if strings.HasSuffix(r.path, ".gz") {
+		if text, err = gzip.NewReader(text); err != nil {
+			return &ParseError{path: r.path, lineNumber: 1, err: err}
+		}
+	}
+
+	r.reader = csv.NewReader(text)
+	r.reader.FieldsPerRecord = -1
+	r.reader.ReuseRecord = true
+	if _, err := r.reader.Read(); err != nil && err != io.EOF {
+		return &ParseError{path: r.path, lineNumber: 1, err: err}
+	}
+	return nil
+}
+
+// "next" returns the next row of the file, nil at the end of its last chunk. A ParseError is returned if a row can
+// not be read or its row id is smaller than the one before
+func (r *OutputFileReader) next() (*OutputRow, error) {
+	fields, err := r.reader.Read()
+	for err == io.EOF {
+		if len(r.paths) == 0 {
+			return nil, nil
+		}
+		if err := r.openNext(); err != nil {
+			return nil, err
+		}
+		fields, err = r.reader.Read()
+	}
+	r.line++
+	if err != nil {
+		return nil, &ParseError{path: r.path, lineNumber: uint64(r.line), err: err}
+	}
+	if len(fields) < FIELDS_PER_RECORD {
+		return nil, &ParseError{path: r.path, lineNumber: uint64(r.line), err: fmt.Errorf("no comma between the row id & postcode")}
+	}
+
+	rowId, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return nil, &ParseError{path: r.path, lineNumber: uint64(r.line), err: err}
+	}
+	if r.rows > 0 && rowId < r.last {
+		return nil, &ParseError{path: r.path, lineNumber: uint64(r.line), err: fmt.Errorf("the file is not sorted by row id")}
+	}
+	r.rows++
+	r.last = rowId
+	return &OutputRow{rowId: rowId, postcode: fields[1], isValid: r.isValid}, nil
+}
+
+// "Close" closes the file being read
+func (r *OutputFileReader) Close() {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+// type that reads every row of a run in row id order, merging its succeeded & failed files as they are read
+type RunReader struct {
+	files []*OutputFileReader
+	heads []*OutputRow
+}
+
+// "NewRunReader" opens the succeeded & failed files of the run in the output directory "dir", which may have been
+// written with -compress gzip or split into chunks
+func NewRunReader(dir string) (*RunReader, error) {
+	r := &RunReader{heads: make([]*OutputRow, 2)}
+	for _, output := range []struct {
+		name    string
+		isValid bool
+	}{{SUCCEEDED_FILE_NAME, true}, {FAILED_FILE_NAME, false}} {
+		paths, err := outputFilePaths(dir, output.name)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		file, err := NewOutputFileReader(paths, output.isValid)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.files = append(r.files, file)
+	}
+
+	var err error
+	for i, file := range r.files {
+		if r.heads[i], err = file.next(); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// "next" returns the row with the smallest row id of either file, nil once both have been read
+func (r *RunReader) next() (*OutputRow, error) {
+	smallest := -1
+	for i, head := range r.heads {
+		if head != nil && (smallest < 0 || head.rowId < r.heads[smallest].rowId) {
+			smallest = i
+		}
+	}
+	if smallest < 0 {
+		return nil, nil
+	}
+
+	row := r.heads[smallest]
+	var err error
+	r.heads[smallest], err = r.files[smallest].next()
+	return row, err
+}
+
+// "Close" closes both files
+func (r *RunReader) Close() {
+	for _, file := range r.files {
+		file.Close()
+	}
+}
+
+// "diffRuns" joins the rows of the runs "old" & "new" on their row id, reading each once in order, & writes each
+// change to "w" as a line of the diff file. Rows with the same row id in one run are paired with those in the other
+// in the order they are read
+func diffRuns(old, new *RunReader, w io.Writer) (DiffSummary, error) {
+	var summary DiffSummary
+	writer := csv.NewWriter(w)
+	writer.Write(DIFF_COLUMNS)
+
+	write := func(change string, oldRow, newRow *OutputRow) {
+		record := make([]string, len(DIFF_COLUMNS))
+		for _, row := range []*OutputRow{oldRow, newRow} {
+			if row != nil {
+				record[0] = strconv.FormatUint(row.rowId, 10)
+			}
+		}
+		record[1] = change
+		if oldRow != nil {
+			record[2], record[4] = oldRow.postcode, strconv.FormatBool(oldRow.isValid)
+		}
+		if newRow != nil {
+			record[3], record[5] = newRow.postcode, strconv.FormatBool(newRow.isValid)
+		}
+		writer.Write(record)
+	}
+
+	oldRow, err := old.next()
+	if err != nil {
+		return summary, err
+	}
+	newRow, err := new.next()
+	if err != nil {
+		return summary, err
+	}
+
+	for oldRow != nil || newRow != nil {
+		switch {
+		case newRow == nil || (oldRow != nil && oldRow.rowId < newRow.rowId):
+			write(CHANGE_REMOVED, oldRow, nil)
+			summary.removed++
+			if oldRow, err = old.next(); err != nil {
+				return summary, err
+			}
+
+		case oldRow == nil || newRow.rowId < oldRow.rowId:
+			write(CHANGE_ADDED, nil, newRow)
+			summary.added++
+			if newRow, err = new.next(); err != nil {
+				return summary, err
+			}
+
+		default:
+			changed := false
+			if !oldRow.isValid && newRow.isValid {
+				write(CHANGE_BECAME_VALID, oldRow, newRow)
+				summary.becameValid++
+				changed = true
+			}
+			if oldRow.isValid && !newRow.isValid {
+				write(CHANGE_BECAME_INVALID, oldRow, newRow)
+				summary.becameInvalid++
+				changed = true
+			}
+			if oldRow.postcode != newRow.postcode {
+				write(CHANGE_POSTCODE, oldRow, newRow)
+				summary.postcodeChange++
+				changed = true
+			}
+			if !changed {
+				summary.unchanged++
+			}
+
+			if oldRow, err = old.next(); err != nil {
+				return summary, err
+			}
+			if newRow, err = new.next(); err != nil {
+				return summary, err
+			}
+		}
+	}
+
+	writer.Flush()
+	return summary, writer.Error()
+}
+
+// "differences" returns the number of rows that changed
+func (s DiffSummary) differences() int {
+	return s.added + s.removed + s.becameValid + s.becameInvalid + s.postcodeChange
+}
+
+// "print" prints the number of rows of each kind of change
+func (s DiffSummary) print() {
+	fmt.Println("-------------------------------------")
+	fmt.Println("         Diff Report")
+	fmt.Println("-------------------------------------")
+	fmt.Printf("Added: %d\n", s.added)
+	fmt.Printf("Removed: %d\n", s.removed)
+	fmt.Printf("Became valid: %d\n", s.becameValid)
+	fmt.Printf("Became invalid: %d\n", s.becameInvalid)
+	fmt.Printf("Postcode changed: %d\n", s.postcodeChange)
+	fmt.Printf("Unchanged: %d\n", s.unchanged)
+	fmt.Println("-------------------------------------")
+}
+
+// "runDiff" compares the two runs described by "opts" & writes the changes to the diff file. Input files are
+// first validated into temporary output directories with their rule profile
+func runDiff(opts DiffOptions) (DiffSummary, error) {
+	oldDir, newDir := opts.oldDir, opts.newDir
+
+	if len(opts.oldInput) > 0 {
+		tempDir, err := ioutil.TempDir("", "diff")
+		if err != nil {
+			return DiffSummary{}, err
+		}
+		defer os.RemoveAll(tempDir)
+
+		oldDir, newDir = filepath.Join(tempDir, "old"), filepath.Join(tempDir, "new")
+		runs := []struct{ input, profile, dir string }{{opts.oldInput, opts.oldProfile, oldDir}, {opts.newInput, opts.newProfile, newDir}}
+		for _, run := range runs {
+			if err := os.Mkdir(run.dir, 0755); err != nil {
+				return DiffSummary{}, err
+			}
+			if _, err := runImport(time.Now(), newDiffRunOptions(run.input, run.profile, run.dir)); err != nil {
+				return DiffSummary{}, err
+			}
+		}
+	}
+
+	old, err := NewRunReader(oldDir)
+	if err != nil {
+		return DiffSummary{}, err
+	}
+	defer old.Close()
+	new, err := NewRunReader(newDir)
+	if err != nil {
+		return DiffSummary{}, err
+	}
+	defer new.Close()
+
+	file, err := os.Create(opts.out)
+	if err != nil {
+		return DiffSummary{}, &OutputError{path: opts.out, err: err}
+	}
+	writer := bufio.NewWriter(file)
+
+	summary, err := diffRuns(old, new, writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if e := file.Close(); err == nil && e != nil {
+		err = &OutputError{path: opts.out, err: e}
+	}
+
+	// the errors reading the runs are already typed, any other is from writing the diff file
+	if err != nil && exitCodeForError(err) == EXIT_USAGE {
+		err = &OutputError{path: opts.out, err: err}
+	}
+	return summary, err
+}
+
+// "newDiffRunOptions" returns the options of a run validating "path" with the rule profile "profile" into "dir",
+// as if no optional flags were given
+func newDiffRunOptions(path, profile, dir string) *ProgramOptions {
+	return &ProgramOptions{
+		paths:           []string{path},
+		profile:         profile,
+		rowIdRange:      ROW_ID_RANGE_DEFAULT,
+		statisticsTopN:  STATISTICS_TOP_N_DEFAULT,
+		outputDir:       dir,
+		maxInvalidRatio: 1,
+		inputFormat:     FORMAT_CSV,
+		encoding:        ENCODING_UTF8,
+		blankLines:      BLANK_LINES_SKIP,
+		malformedRows:   MALFORMED_ROWS_ERROR,
+		outputFormat:    FORMAT_CSV,
+		jsonFields:      JsonFieldNames{id: JSON_ID_FIELD_DEFAULT, postcode: JSON_POSTCODE_FIELD_DEFAULT},
+		compress:        COMPRESS_NONE,
+	}
+}
+
+// "runDiffCommand" parses the flags of the diff subcommand, compares the two runs & exits with EXIT_DIFFERENCES
+// if they differ
+func runDiffCommand(args []string) {
+	flags := flag.NewFlagSet(DIFF_COMMAND, flag.ExitOnError)
+
+	opts := DiffOptions{}
+	flags.StringVar(&opts.oldDir, "old", "", "the output directory of the old run, holding its "+SUCCEEDED_FILE_NAME+" & "+FAILED_FILE_NAME)
+	flags.StringVar(&opts.newDir, "new", "", "the output directory of the new run, holding its "+SUCCEEDED_FILE_NAME+" & "+FAILED_FILE_NAME)
+	flags.StringVar(&opts.oldInput, "old-input", "", "a .csv file to validate with -old-profile as the old run, instead of -old")
+	flags.StringVar(&opts.newInput, "new-input", "", "a .csv file to validate with -new-profile as the new run, instead of -new")
+	flags.StringVar(&opts.oldProfile, "old-profile", PROFILE_BRIEF_2017, "the rule profile -old-input is validated with")
+	flags.StringVar(&opts.newProfile, "new-profile", PROFILE_BRIEF_2017, "the rule profile -new-input is validated with")
+	flags.StringVar(&opts.out, "out", DIFF_OUT_DEFAULT, "the location of the .csv file each change is written to")
+	flags.Parse(args)
+
+	byDir := len(opts.oldDir) > 0 && len(opts.newDir) > 0 && len(opts.oldInput) == 0 && len(opts.newInput) == 0
+	byInput := len(opts.oldInput) > 0 && len(opts.newInput) > 0 && len(opts.oldDir) == 0 && len(opts.newDir) == 0
+	if !byDir && !byInput {
+		errorExit("Give either -old & -new (two output directories) or -old-input & -new-input (two .csv files)", EXIT_USAGE)
+	}
+
+	summary, err := runDiff(opts)
+	if err != nil {
+		errorExit(err.Error(), exitCodeForError(err))
+	}
+	summary.print()
+	if summary.differences() > 0 {
+		os.Exit(EXIT_DIFFERENCES)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// "writeRun" writes the output files of a run holding "valid" & "invalid" (lines without the header) to a new
+// directory in "dir" & returns its path
+func writeRun(t *testing.T, dir, name string, valid, invalid []string) string {
+	runDir := filepath.Join(dir, name)
+	if err := os.Mkdir(runDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]string{SUCCEEDED_FILE_NAME: valid, FAILED_FILE_NAME: invalid}
+	for fileName, lines := range files {
+		text := strings.Join(append([]string{"row_id,postcode"}, lines...), "\n") + "\n"
+		if err := ioutil.WriteFile(filepath.Join(runDir, fileName), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return runDir
+}
+
+// "readDiffLines" returns the lines of the diff file "path" without the header
+func readDiffLines(t *testing.T, path string) []string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if lines[0] != strings.Join(DIFF_COLUMNS, ",") {
+		t.Fatalf("Given diff file: %s, Expected header: %s   got: %s", path, strings.Join(DIFF_COLUMNS, ","), lines[0])
+	}
+	return lines[1:]
+}
+
+// expected: one line for each row added, removed, flipped or with a changed postcode, in row id order
+func Test_runDiff__OutputDirectories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldDir := writeRun(t, dir, "old", []string{"1,AA1 1AA", "3,BB1 1BB", "5,EE1 1EE"}, []string{"2,junk", "4,CC11CC"})
+	newDir := writeRun(t, dir, "new", []string{"1,AA1 1AA", "2,AB1 1AB", "6,FF1 1FF"}, []string{"3,BB1 1BB", "4,CC11CD"})
+	out := filepath.Join(dir, DIFF_OUT_DEFAULT)
+
+	summary, err := runDiff(DiffOptions{oldDir: oldDir, newDir: newDir, out: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"2,became_valid,junk,AB1 1AB,false,true",
+		"2,postcode_changed,junk,AB1 1AB,false,true",
+		"3,became_invalid,BB1 1BB,BB1 1BB,true,false",
+		"4,postcode_changed,CC11CC,CC11CD,false,false",
+		"5,removed,EE1 1EE,,true,",
+		"6,added,,FF1 1FF,,true",
+	}
+	if lines := readDiffLines(t, out); !reflect.DeepEqual(lines, expected) {
+		error := fmt.Sprintf("Given two runs, Expected: %q   got: %q", expected, lines)
+		t.Error(error)
+	}
+
+	expectedSummary := DiffSummary{added: 1, removed: 1, becameValid: 1, becameInvalid: 1, postcodeChange: 2, unchanged: 1}
+	if summary != expectedSummary {
+		error := fmt.Sprintf("Given two runs, Expected summary: %+v   got: %+v", expectedSummary, summary)
+		t.Error(error)
+	}
+}
+
+// expected: a ParseError naming the file whose row ids are not in order
+func Test_runDiff__NotSorted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldDir := writeRun(t, dir, "old", []string{"1,AA1 1AA", "3,BB1 1BB"}, nil)
+	newDir := writeRun(t, dir, "new", []string{"3,BB1 1BB", "1,AA1 1AA"}, nil)
+
+	_, err = runDiff(DiffOptions{oldDir: oldDir, newDir: newDir, out: filepath.Join(dir, DIFF_OUT_DEFAULT)})
+	parseErr, ok := err.(*ParseError)
+	if !ok || parseErr.path != filepath.Join(newDir, SUCCEEDED_FILE_NAME) || parseErr.lineNumber != 3 {
+		error := fmt.Sprintf("Given an unsorted %s, Expected: a ParseError on line 3 of it   got: %v", SUCCEEDED_FILE_NAME, err)
+		t.Error(error)
+	}
+}
+
+// expected: the same input validated with two rule profiles differs only in the rows the profiles disagree on
+func Test_runDiff__InputsAndProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "import_data.csv")
+	text := "row_id,postcode\n1,EC1A 1BB\n2,junk\n3,BB11 1AA\n4,ec1a1bb\n"
+	if err := ioutil.WriteFile(input, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, DIFF_OUT_DEFAULT)
+	opts := DiffOptions{oldInput: input, newInput: input, oldProfile: PROFILE_BRIEF_2017, newProfile: PROFILE_BRIEF_2017, out: out}
+	summary, err := runDiff(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.differences() != 0 || summary.unchanged != 4 {
+		error := fmt.Sprintf("Given the same input & profile twice, Expected: no differences   got: %+v", summary)
+		t.Error(error)
+	}
+
+	opts.newProfile = PROFILE_PERMISSIVE
+	if summary, err = runDiff(opts); err != nil {
+		t.Fatal(err)
+	}
+	if summary.becameValid == 0 {
+		error := fmt.Sprintf("Given profiles %s & %s, Expected: a row that became valid   got: %+v", PROFILE_BRIEF_2017, PROFILE_PERMISSIVE, summary)
+		t.Error(error)
+	}
+	for _, line := range readDiffLines(t, out) {
+		if !strings.Contains(line, ","+CHANGE_BECAME_VALID+",") {
+			error := fmt.Sprintf("Given profiles %s & %s, Expected: only rows that became valid   got: %s", PROFILE_BRIEF_2017, PROFILE_PERMISSIVE, line)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: output directories written with -compress gzip, split into chunks or both are read like a plain one,
+// so the same input validated each way has no differences
+func Test_runDiff__CompressedAndChunkedOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "import_data.csv")
+	check(writeImportDataFile(input, GeneratorOptions{rows: 200, seed: 4, validRatio: 0.7, shuffle: true, quoting: QUOTING_NONE}))
+
+	testCases := []struct {
+		name           string
+		compress       string
+		maxRowsPerFile int
+	}{
+		{"plain", COMPRESS_NONE, 0},
+		{"gzip", COMPRESS_GZIP, 0},
+		{"chunked", COMPRESS_NONE, 30},
+		{"gzip_chunked", COMPRESS_GZIP, 30},
+	}
+
+	runDirs := make([]string, len(testCases))
+	for i, element := range testCases {
+		runDirs[i] = filepath.Join(dir, element.name)
+		check(os.Mkdir(runDirs[i], 0755))
+		opts := newDefaultProgramOptions(input, runDirs[i])
+		opts.compress = element.compress
+		opts.maxRowsPerFile = element.maxRowsPerFile
+		if _, err := runImport(time.Now(), opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, element := range testCases[1:] {
+		summary, err := runDiff(DiffOptions{oldDir: runDirs[0], newDir: runDirs[i+1], out: filepath.Join(dir, DIFF_OUT_DEFAULT)})
+		if err != nil || summary.differences() != 0 || summary.unchanged != 200 {
+			error := fmt.Sprintf("Given a plain run & a %s run of the same input, Expected: 200 unchanged rows   got: %+v (error: %v)", element.name, summary, err)
+			t.Error(error)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the name of the file duplicates are written to
+const DUPLICATES_FILE_NAME = "duplicates.csv"
+
+// the kinds of duplicate that can be found
+const (
+	DUPLICATE_ROW_ID   = "row_id"
+	DUPLICATE_POSTCODE = "postcode"
+)
+
+// type that stores a value found on more than one line of the input files and the lines it was found on
+type DuplicateGroup struct {
+	kind      string
+	value     string
+	locations []RecordLocation
+}
+
+// "sortLocations" sorts "locations" into the order the lines were read in
+func sortLocations(locations []RecordLocation) {
+	sort.Slice(locations, func(i, j int) bool { return locations[i].before(locations[j]) })
+}
+
+// "findDuplicateRowIds" returns a DuplicateGroup for each rowId that is found on more than one line. Both
+// groups must already be sorted, they are merged so that an id can be duplicated across valid & invalid records
+func findDuplicateRowIds(validRecs, invalidRecs ImportRecordGroup) []DuplicateGroup {
+	var groups []DuplicateGroup
+	var run []*ImportRecord
+
+	// adds the current run of records to the result if it has more than one record in it
+	flushRun := func() {
+		if len(run) > 1 {
+			group := DuplicateGroup{kind: DUPLICATE_ROW_ID, value: strconv.FormatUint(run[0].rowId, 10)}
+			for _, rec := range run {
+				group.locations = append(group.locations, rec.location())
+			}
+			sortLocations(group.locations)
+			groups = append(groups, group)
+		}
+		run = run[:0]
+	}
+
+	i, j := 0, 0
+	for i < len(validRecs) || j < len(invalidRecs) {
+		// take the smaller of the next record of each group
+		var rec *ImportRecord
+		if j >= len(invalidRecs) || (i < len(validRecs) && validRecs[i].rowId <= invalidRecs[j].rowId) {
+			rec = validRecs[i]
+			i++
+		} else {
+			rec = invalidRecs[j]
+			j++
+		}
+
+		if len(run) > 0 && run[0].rowId != rec.rowId {
+			flushRun()
+		}
+		run = append(run, rec)
+	}
+	flushRun()
+
+	return groups
+}
+
+// "findDuplicatePostcodes" returns a DuplicateGroup for each normalised postcode (upper-cased with whitespace
+// removed) found on more than one line, the groups are ordered by the first line each postcode was found on
+func findDuplicatePostcodes(validRecs, invalidRecs ImportRecordGroup) []DuplicateGroup {
+	lines := make(map[string][]RecordLocation)
+
+	for _, coll := range []ImportRecordGroup{validRecs, invalidRecs} {
+		for _, rec := range coll {
+			key := normalisePostcode(rec.postcode)
+			if len(key) > 0 {
+				lines[key] = append(lines[key], rec.location())
+			}
+		}
+	}
+
+	var groups []DuplicateGroup
+	for postcode, locations := range lines {
+		if len(locations) > 1 {
+			sortLocations(locations)
+			groups = append(groups, DuplicateGroup{kind: DUPLICATE_POSTCODE, value: postcode, locations: locations})
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].locations[0].before(groups[j].locations[0]) })
+
+	return groups
+}
+
+// "normalisePostcode" upper-cases a postcode and removes all whitespace from it
+func normalisePostcode(postcode string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(postcode), ""))
+}
+
+// "writeDuplicatesFile" writes each DuplicateGroup to a csv file at "path", one group per row. The line
+// numbers of each group are separated by semicolons, each is written as "path:line" when "withSources" is set
+func writeDuplicatesFile(path string, groups []DuplicateGroup, withSources bool) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	fmt.Fprintln(writer, "kind,value,count,line_numbers")
+
+	for _, group := range groups {
+		lineNumbers := make([]string, len(group.locations))
+		for i, location := range group.locations {
+			lineNumbers[i] = location.format(withSources)
+		}
+		fmt.Fprintf(writer, "%s,%s,%d,%s\n", group.kind, csvField(group.value), len(group.locations), csvField(strings.Join(lineNumbers, ";")))
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
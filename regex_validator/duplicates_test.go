@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// "newTestRecord" creates an ImportRecord with the given row id, postcode & line number
+func newTestRecord(rowId uint64, postcode string, lineNumber uint64) *ImportRecord {
+	return &ImportRecord{rowId: rowId, postcode: postcode, lineNumber: lineNumber}
+}
+
+// expected: one group for each repeated row id, including ids repeated across the valid & invalid groups
+func Test_findDuplicateRowIds(t *testing.T) {
+
+	valid := ImportRecordGroup{newTestRecord(1, "M1 1AE", 2), newTestRecord(3, "B33 8TH", 4), newTestRecord(3, "CR2 6XH", 7)}
+	invalid := ImportRecordGroup{newTestRecord(1, "LS44PL", 5), newTestRecord(2, "XX XXX", 3)}
+	sort.Sort(valid)
+	sort.Sort(invalid)
+
+	expected := []DuplicateGroup{
+		{kind: DUPLICATE_ROW_ID, value: "1", locations: []RecordLocation{{lineNumber: 2}, {lineNumber: 5}}},
+		{kind: DUPLICATE_ROW_ID, value: "3", locations: []RecordLocation{{lineNumber: 4}, {lineNumber: 7}}},
+	}
+	result := findDuplicateRowIds(valid, invalid)
+
+	if !reflect.DeepEqual(result, expected) {
+		error := fmt.Sprintf("Expected: %v   got: %v", expected, result)
+		t.Error(error)
+	}
+}
+
+// expected: one group for each postcode repeated once normalised, ordered by the first line it was found on
+func Test_findDuplicatePostcodes(t *testing.T) {
+
+	valid := ImportRecordGroup{newTestRecord(1, "M1 1AE", 2), newTestRecord(4, "B33 8TH", 9)}
+	invalid := ImportRecordGroup{newTestRecord(2, "m11ae", 6), newTestRecord(3, "b33  8th", 3), newTestRecord(5, "XX XXX", 4)}
+
+	expected := []DuplicateGroup{
+		{kind: DUPLICATE_POSTCODE, value: "M11AE", locations: []RecordLocation{{lineNumber: 2}, {lineNumber: 6}}},
+		{kind: DUPLICATE_POSTCODE, value: "B338TH", locations: []RecordLocation{{lineNumber: 3}, {lineNumber: 9}}},
+	}
+	result := findDuplicatePostcodes(valid, invalid)
+
+	if !reflect.DeepEqual(result, expected) {
+		error := fmt.Sprintf("Expected: %v   got: %v", expected, result)
+		t.Error(error)
+	}
+}
+
+// expected: records with the same row id are sorted by their line number
+func Test_ImportRecordGroup_Sort__EqualRowIds(t *testing.T) {
+
+	coll := ImportRecordGroup{newTestRecord(2, "C", 9), newTestRecord(1, "B", 5), newTestRecord(2, "A", 3), newTestRecord(1, "D", 4)}
+	sort.Sort(coll)
+
+	expected := []uint64{4, 5, 3, 9}
+	for i, rec := range coll {
+		if rec.lineNumber != expected[i] {
+			error := fmt.Sprintf("At index: %d, Expected line: %d   got: %d", i, expected[i], rec.lineNumber)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: every row has 4 fields & the postcode is read back as it was, including one holding a comma & a quote
+// (which a quoted field of the input file can)
+func Test_writeDuplicatesFile__QuotedComma(t *testing.T) {
+	dir, err := ioutil.TempDir("", "duplicates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	valid := ImportRecordGroup{newTestRecord(1, "A,B", 2), newTestRecord(3, `C"D`, 4)}
+	invalid := ImportRecordGroup{newTestRecord(2, "a,b", 3), newTestRecord(4, `c"d`, 5)}
+	path := filepath.Join(dir, DUPLICATES_FILE_NAME)
+	if err := writeDuplicatesFile(path, findDuplicatePostcodes(valid, invalid), false); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{
+		{"kind", "value", "count", "line_numbers"},
+		{DUPLICATE_POSTCODE, "A,B", "2", "2;3"},
+		{DUPLICATE_POSTCODE, `C"D`, "2", "4;5"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		error := fmt.Sprintf("Given postcodes with a comma & a quote, Expected: %q   got: %q", expected, rows)
+		t.Error(error)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// names of the character encodings input files can be read in, the text is turned into UTF-8 before it is split
+// into records. "utf-16" is little endian unless the file starts with a byte order mark
+const (
+	ENCODING_UTF8         = "utf-8"
+	ENCODING_WINDOWS_1252 = "windows-1252"
+	ENCODING_LATIN1       = "latin-1"
+	ENCODING_UTF16        = "utf-16"
+	ENCODING_UTF16LE      = "utf-16le"
+	ENCODING_UTF16BE      = "utf-16be"
+)
+
+// the byte order marks a file can start with, a byte order mark decides the encoding of the file whatever
+// -encoding is given as none of the other encodings are likely to start with these bytes
+var BYTE_ORDER_MARKS = []struct {
+	bytes    string
+	encoding string
+}{
+	{"\xEF\xBB\xBF", ENCODING_UTF8},
+	{"\xFF\xFE", ENCODING_UTF16LE},
+	{"\xFE\xFF", ENCODING_UTF16BE},
+}
+
+// the characters of bytes 0x80 to 0x9F in Windows-1252, the 5 bytes it does not use are utf8.RuneError. Every
+// other byte is the same character as in Latin-1
+var WINDOWS_1252_CHARACTERS = [32]rune{
+	'€', utf8.RuneError, '‚', 'ƒ', '„', '…', '†', '‡',
+	'ˆ', '‰', 'Š', '‹', 'Œ', utf8.RuneError, 'Ž', utf8.RuneError,
+	utf8.RuneError, '‘', '’', '“', '”', '•', '–', '—',
+	'˜', '™', 'š', '›', 'œ', utf8.RuneError, 'ž', 'Ÿ',
+}
+
+// the error a line holding a byte sequence that is not valid in its encoding is reported with, as a ParseError
+var errInvalidEncoding = errors.New("the line holds a byte sequence that is not valid in the input's character encoding (see -encoding)")
+
+// the encodings, each a function that returns the next character read from "src" (utf8.RuneError if the bytes
+// read are not a character of the encoding). UTF-8 is read as it is so it has none
+var textEncodings = map[string]func(src *bufio.Reader) func() (rune, error){
+	ENCODING_UTF8:         nil,
+	ENCODING_WINDOWS_1252: windows1252Decoder,
+	ENCODING_LATIN1:       latin1Decoder,
+	ENCODING_UTF16:        func(src *bufio.Reader) func() (rune, error) { return utf16Decoder(src, false) },
+	ENCODING_UTF16LE:      func(src *bufio.Reader) func() (rune, error) { return utf16Decoder(src, false) },
+	ENCODING_UTF16BE:      func(src *bufio.Reader) func() (rune, error) { return utf16Decoder(src, true) },
+}
+
+// "textEncodingNames" returns the name of each encoding, sorted
+func textEncodingNames() []string {
+	names := make([]string, 0, len(textEncodings))
+	for name := range textEncodings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// "checkEncoding" returns an error if "name" is not an encoding input files can be read in
+func checkEncoding(name string) error {
+	if _, ok := textEncodings[name]; !ok {
+		return fmt.Errorf("unknown encoding \"%s\", must be one of: %s", name, strings.Join(textEncodingNames(), ", "))
+	}
+	return nil
+}
+
+// "openInputText" returns a reader of the text of the input file "file" in UTF-8, starting "offset" bytes into
+// the text. Any byte order mark is skipped & decides the encoding, otherwise the file is read in "encoding"
+// (UTF-8 if it is empty). "offset" counts bytes of the UTF-8 text so a checkpoint is the same whatever the
+// encoding, a UTF-8 file is seeked to it while a file in another encoding is decoded up to it
+func openInputText(file *os.File, encoding string, offset int64) (*bufio.Reader, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	raw := bufio.NewReader(file)
+
+	if len(encoding) == 0 {
+		encoding = ENCODING_UTF8
+	}
+	start, _ := raw.Peek(3)
+	bomLength := 0
+	for _, bom := range BYTE_ORDER_MARKS {
+		if strings.HasPrefix(string(start), bom.bytes) {
+			encoding, bomLength = bom.encoding, len(bom.bytes)
+			break
+		}
+	}
+	raw.Discard(bomLength)
+
+	decoder := textEncodings[encoding]
+	if decoder == nil {
+		if offset > 0 {
+			if _, err := file.Seek(int64(bomLength)+offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+			raw.Reset(file)
+		}
+		return raw, nil
+	}
+
+	text := bufio.NewReader(&decodingReader{next: decoder(raw)})
+	if _, err := io.CopyN(ioutil.Discard, text, offset); err != nil {
+		return nil, err
+	}
+	return text, nil
+}
+
+// "checkLineEncoding" returns errInvalidEncoding if "line" is not valid UTF-8 or holds a character that could
+// not be decoded
+func checkLineEncoding(line string) error {
+	if !utf8.ValidString(line) || strings.ContainsRune(line, utf8.RuneError) {
+		return errInvalidEncoding
+	}
+	return nil
+}
+
+// type of reader that writes each character returned by "next" as UTF-8
+type decodingReader struct {
+	next    func() (rune, error)
+	buf     [utf8.UTFMax]byte
+	pending []byte
+	err     error
+}
+
+func (d *decodingReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(d.pending) > 0 {
+			copied := copy(p[n:], d.pending)
+			d.pending = d.pending[copied:]
+			n += copied
+			continue
+		}
+		if d.err != nil {
+			break
+		}
+
+		r, err := d.next()
+		if err != nil {
+			d.err = err
+			break
+		}
+		d.pending = d.buf[:utf8.EncodeRune(d.buf[:], r)]
+	}
+
+	if n > 0 {
+		return n, nil
+	}
+	return 0, d.err
+}
+
+// "latin1Decoder" reads Latin-1 (ISO-8859-1), in which each byte is the character with the same code
+func latin1Decoder(src *bufio.Reader) func() (rune, error) {
+	return func() (rune, error) {
+		b, err := src.ReadByte()
+		return rune(b), err
+	}
+}
+
+// "windows1252Decoder" reads Windows-1252, Latin-1 with printable characters in place of most of 0x80 to 0x9F
+func windows1252Decoder(src *bufio.Reader) func() (rune, error) {
+	return func() (rune, error) {
+		b, err := src.ReadByte()
+		if b >= 0x80 && b <= 0x9F {
+			return WINDOWS_1252_CHARACTERS[b-0x80], err
+		}
+		return rune(b), err
+	}
+}
+
+// "utf16Decoder" reads UTF-16, little endian unless "bigEndian" is set. A surrogate that is not part of a pair
+// & a last byte that is not part of a code unit are utf8.RuneError
+func utf16Decoder(src *bufio.Reader, bigEndian bool) func() (rune, error) {
+	var pair [2]byte
+	readUnit := func() (uint16, error) {
+		if _, err := io.ReadFull(src, pair[:]); err != nil {
+			return utf8.RuneError, err
+		}
+		if bigEndian {
+			return uint16(pair[0])<<8 | uint16(pair[1]), nil
+		}
+		return uint16(pair[1])<<8 | uint16(pair[0]), nil
+	}
+
+	// the unit read after a high surrogate that was not a low surrogate is the next character
+	var held *uint16
+	return func() (rune, error) {
+		var unit uint16
+		if held != nil {
+			unit, held = *held, nil
+		} else {
+			var err error
+			if unit, err = readUnit(); err == io.ErrUnexpectedEOF {
+				return utf8.RuneError, nil
+			} else if err != nil {
+				return 0, err
+			}
+		}
+
+		if !utf16.IsSurrogate(rune(unit)) {
+			return rune(unit), nil
+		}
+		low, err := readUnit()
+		if err != nil {
+			return utf8.RuneError, nil
+		}
+		if r := utf16.DecodeRune(rune(unit), rune(low)); r != utf8.RuneError {
+			return r, nil
+		}
+		held = &low
+		return utf8.RuneError, nil
+	}
+}
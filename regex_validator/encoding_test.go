@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// "readInputText" writes "contents" to a file & returns its text read with "openInputText" from "offset"
+func readInputText(t *testing.T, contents, encoding string, offset int64) string {
+	dir, err := ioutil.TempDir("", "encoding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "input.csv")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := openInputText(file, encoding, offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(text)
+}
+
+// expected: the text of each encoding as UTF-8 without its byte order mark, a byte order mark decides the encoding
+func Test_openInputText__Encodings(t *testing.T) {
+	tests := []struct {
+		contents string
+		encoding string
+		expected string
+	}{
+		{"1,Zürich\n", ENCODING_UTF8, "1,Zürich\n"},
+		{"\xEF\xBB\xBF1,Zürich\n", ENCODING_UTF8, "1,Zürich\n"},
+		{"\xEF\xBB\xBF1,Zürich\n", ENCODING_LATIN1, "1,Zürich\n"},
+		{"1,Z\xFCrich \x80\x9F\n", ENCODING_WINDOWS_1252, "1,Zürich €Ÿ\n"},
+		{"1,Z\xFCrich \x80\n", ENCODING_LATIN1, "1,Zürich \u0080\n"},
+		{"1\x00,\x00\xFC\x00", ENCODING_UTF16, "1,ü"},
+		{"\xFE\xFF\x001\x00,\x00\xFC", ENCODING_UTF8, "1,ü"},
+		{"\x001\x00,\xD8\x3D\xDE\x00", ENCODING_UTF16BE, "1,😀"},
+		{"", ENCODING_UTF16LE, ""},
+	}
+
+	for _, test := range tests {
+		if text := readInputText(t, test.contents, test.encoding, 0); text != test.expected {
+			error := fmt.Sprintf("Given: %q in %s, Expected: %q   got: %q", test.contents, test.encoding, test.expected, text)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: an offset counts bytes of the UTF-8 text whatever the encoding of the file
+func Test_openInputText__Offset(t *testing.T) {
+	tests := []struct {
+		contents string
+		encoding string
+	}{
+		{"\xEF\xBB\xBFrow_id,postcode\n1,Zürich\n2,EC1A 1BB\n", ENCODING_UTF8},
+		{"row_id,postcode\n1,Z\xFCrich\n2,EC1A 1BB\n", ENCODING_WINDOWS_1252},
+		{"\xFF\xFEr\x00o\x00w\x00_\x00i\x00d\x00,\x00p\x00o\x00s\x00t\x00c\x00o\x00d\x00e\x00\n\x001\x00,\x00Z\x00\xFC\x00r\x00i\x00c\x00h\x00\n\x00" +
+			"2\x00,\x00E\x00C\x001\x00A\x00 \x001\x00B\x00B\x00\n\x00", ENCODING_UTF8},
+	}
+
+	offset := int64(len("row_id,postcode\n1,Zürich\n"))
+	for _, test := range tests {
+		if text := readInputText(t, test.contents, test.encoding, offset); text != "2,EC1A 1BB\n" {
+			error := fmt.Sprintf("Given: %q from offset %d, Expected: %q   got: %q", test.contents, offset, "2,EC1A 1BB\n", text)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: a line that is not valid UTF-8 or holds a character that could not be decoded is an error
+func Test_checkLineEncoding(t *testing.T) {
+	tests := map[string]bool{
+		"1,EC1A 1BB\n":  true,
+		"1,Zürich\n":    true,
+		"1,Z\xFCrich\n": false,
+		"1,�\n":         false,
+	}
+
+	for line, valid := range tests {
+		if err := checkLineEncoding(line); (err == nil) != valid {
+			error := fmt.Sprintf("Given: %q, Expected valid: %t   got error: %v", line, valid, err)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: surrogates that are not a pair & a byte left over are replaced, the character after them is kept
+func Test_utf16Decoder__Invalid(t *testing.T) {
+	tests := map[string]string{
+		"\x3D\xD8a\x00": "�a",
+		"\x00\xDCa\x00": "�a",
+		"a\x00\x3D\xD8": "a�",
+		"a\x00b":        "a�",
+	}
+
+	for contents, expected := range tests {
+		if text := readInputText(t, contents, ENCODING_UTF16LE, 0); text != expected {
+			error := fmt.Sprintf("Given: %q, Expected: %q   got: %q", contents, expected, text)
+			t.Error(error)
+		}
+	}
+}
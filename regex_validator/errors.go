@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// the codes the program exits with, so scripts that run it can tell what went wrong
+const (
+	EXIT_ALL_VALID     int = 0 // every record is valid
+	EXIT_USAGE         int = 1 // a flag was missing or not valid
+	EXIT_SOME_INVALID  int = 2 // the run completed but at least one record is invalid
+	EXIT_MALFORMED     int = 3 // a row could not be read as a "row_id,postcode" pair, no output files were written
+	EXIT_IO_FAILURE    int = 4 // a file could not be opened, read or written
+	EXIT_INVALID_RATIO int = 5 // the run completed but the share of invalid records is above -max-invalid-ratio
+	EXIT_DIFFERENCES   int = 6 // the diff subcommand found at least one row that changed between the two runs
+)
+
+// type of error returned when an input file (the import file or the postcode directory) can not be opened or read
+type InputError struct {
+	path string
+	err  error
+}
+
+func (e *InputError) Error() string { return fmt.Sprintf("Could not read \"%s\": %s", e.path, e.err) }
+func (e *InputError) Unwrap() error { return e.err }
+func (e *InputError) exitCode() int { return EXIT_IO_FAILURE }
+
+// type of error returned when a line of the import file is not a "row_id,postcode" pair, "path" is the input
+// file the line was read from (empty if it is not known)
+type ParseError struct {
+	path       string
+	lineNumber uint64
+	err        error
+}
+
+func (e *ParseError) Error() string {
+	if len(e.path) > 0 {
+		return fmt.Sprintf("Invalid record on line %d of \"%s\": %s", e.lineNumber, e.path, e.err)
+	}
+	return fmt.Sprintf("Invalid record on line %d: %s", e.lineNumber, e.err)
+}
+func (e *ParseError) Unwrap() error { return e.err }
+func (e *ParseError) exitCode() int { return EXIT_MALFORMED }
+
+// type of error returned when the share of invalid records is above the threshold given with -max-invalid-ratio
+type ValidationError struct {
+	numInvalid int
+	total      int
+	threshold  float64
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d of %d records are invalid (%.2f%%), above the threshold of %.2f%%", e.numInvalid, e.total,
+		100*float64(e.numInvalid)/float64(e.total), 100*e.threshold)
+}
+func (e *ValidationError) exitCode() int { return EXIT_INVALID_RATIO }
+
+// type of error returned when an output file can not be written
+type OutputError struct {
+	path string
+	err  error
+}
+
+func (e *OutputError) Error() string { return fmt.Sprintf("Could not write \"%s\": %s", e.path, e.err) }
+func (e *OutputError) Unwrap() error { return e.err }
+func (e *OutputError) exitCode() int { return EXIT_IO_FAILURE }
+
+// "exitCodeForError" returns the code the program exits with when a run ends with the error "err", errors that
+// are not one of the types above are treated as a usage error
+func exitCodeForError(err error) int {
+	if err == nil {
+		return EXIT_ALL_VALID
+	}
+
+	var coder interface{ exitCode() int }
+	if errors.As(err, &coder) {
+		return coder.exitCode()
+	}
+	return EXIT_USAGE
+}
+
+// type that stores the first error found by the routines of the pipeline, "first" being the error found at the
+// earliest location of the input files so the same files always report the same error whatever order the
+// routines run in. It also counts the malformed rows skipped rather than reported (-malformed-rows skip)
+type PipelineError struct {
+	mutex     sync.Mutex
+	location  RecordLocation
+	err       error
+	malformed int
+}
+
+// "Set" stores the error "err" found at "location" if it is before any error already stored
+func (p *PipelineError) Set(location RecordLocation, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.err == nil || location.before(p.location) {
+		p.location = location
+		p.err = err
+	}
+}
+
+// "SkipMalformed" counts a malformed row that was skipped
+func (p *PipelineError) SkipMalformed() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.malformed++
+}
+
+// "Malformed" returns the number of malformed rows skipped
+func (p *PipelineError) Malformed() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.malformed
+}
+
+// "Err" returns the error stored, nil if there is none
+func (p *PipelineError) Err() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.err
+}
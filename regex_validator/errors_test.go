@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// expected: each type of error maps to its documented exit code, wrapped or not
+func Test_exitCodeForError__EachType(t *testing.T) {
+
+	testCases := []struct {
+		err      error
+		expected int
+	}{
+		{nil, EXIT_ALL_VALID},
+		{errors.New("unknown rule profile"), EXIT_USAGE},
+		{&ParseError{lineNumber: 3, err: errors.New("no comma")}, EXIT_MALFORMED},
+		{&InputError{path: "in.csv", err: os.ErrNotExist}, EXIT_IO_FAILURE},
+		{&OutputError{path: "out.csv", err: errors.New("no space left on device")}, EXIT_IO_FAILURE},
+		{&ValidationError{numInvalid: 2, total: 4, threshold: 0.1}, EXIT_INVALID_RATIO},
+		{fmt.Errorf("wrapped: %w", &ParseError{lineNumber: 3, err: errors.New("no comma")}), EXIT_MALFORMED},
+	}
+
+	for _, element := range testCases {
+		result := exitCodeForError(element.err)
+
+		if result != element.expected {
+			error := fmt.Sprintf("Given error: %v, Expected: %d   got: %d", element.err, element.expected, result)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: the underlying error can be found through each type of error
+func Test_InputError__Unwrap(t *testing.T) {
+	err := error(&InputError{path: "in.csv", err: os.ErrNotExist})
+
+	if !errors.Is(err, os.ErrNotExist) {
+		error := fmt.Sprintf("Given error: %v, Expected: errors.Is(err, os.ErrNotExist)   got: false", err)
+		t.Error(error)
+	}
+}
+
+// expected: the error on the lowest line is kept whatever order the errors are set in
+func Test_PipelineError__LowestLineKept(t *testing.T) {
+	var p PipelineError
+
+	if p.Err() != nil {
+		t.Error("Given no errors set, Expected: nil   got: an error")
+	}
+
+	p.Set(RecordLocation{lineNumber: 10}, errors.New("line 10"))
+	p.Set(RecordLocation{lineNumber: 4}, errors.New("line 4"))
+	p.Set(RecordLocation{lineNumber: 7}, errors.New("line 7"))
+
+	if result := p.Err(); result == nil || result.Error() != "line 4" {
+		error := fmt.Sprintf("Given errors on lines 10, 4 & 7, Expected: line 4   got: %v", result)
+		t.Error(error)
+	}
+
+	// an error in an earlier input file comes first whatever its line
+	inputs := newInputFiles([]string{"a.csv", "b.csv"})
+	p.Set(RecordLocation{source: inputs[1], lineNumber: 2}, errors.New("b.csv line 2"))
+	p.Set(RecordLocation{source: inputs[0], lineNumber: 9}, errors.New("a.csv line 9"))
+
+	if result := p.Err(); result == nil || result.Error() != "line 4" {
+		error := fmt.Sprintf("Given errors on a.csv line 9 & b.csv line 2 after line 4, Expected: line 4   got: %v", result)
+		t.Error(error)
+	}
+
+	var q PipelineError
+	q.Set(RecordLocation{source: inputs[1], lineNumber: 2}, errors.New("b.csv line 2"))
+	q.Set(RecordLocation{source: inputs[0], lineNumber: 9}, errors.New("a.csv line 9"))
+
+	if result := q.Err(); result == nil || result.Error() != "a.csv line 9" {
+		error := fmt.Sprintf("Given errors on b.csv line 2 & a.csv line 9, Expected: a.csv line 9   got: %v", result)
+		t.Error(error)
+	}
+}
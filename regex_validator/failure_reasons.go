@@ -0,0 +1,167 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// the reasons a postcode can be found invalid, named after the "Expected problem" column of the Part 1 table.
+// A postcode the table only calls "Invalid" is given a more specific reason where the problem can be pinned to
+// one part of it ("XX XXX" is REASON_INWARD_CODE), REASON_INVALID is left for those where it cannot
+const (
+	REASON_EMPTY              = "empty"
+	REASON_JUNK               = "junk"
+	REASON_NO_SPACE           = "no_space"
+	REASON_INWARD_CODE_LENGTH = "incorrect_inward_code_length"
+	REASON_INWARD_CODE        = "invalid_inward_code"
+	REASON_LOWER_CASE         = "lower_case"
+	REASON_FIRST_POSITION     = "invalid_first_position"
+	REASON_SECOND_POSITION    = "invalid_second_position"
+	REASON_THIRD_POSITION     = "invalid_third_position"
+	REASON_FOURTH_POSITION    = "invalid_fourth_position"
+	REASON_SINGLE_DIGIT_AREA  = "area_with_only_single_digit_districts"
+	REASON_DOUBLE_DIGIT_AREA  = "area_with_only_double_digit_districts"
+	REASON_INVALID            = "invalid"
+
+	// the reasons only the royalmail-current profile gives, for the rules it adds to the Part 1 rules
+	REASON_DISTRICT_ZERO           = "district_zero_not_used_in_area"
+	REASON_DIVIDED_DISTRICT_LETTER = "divided_district_without_letter"
+	REASON_DISTRICT_NOT_DIVIDED    = "district_not_divided"
+)
+
+// regexs used to work out which part of an invalid postcode is wrong
+var (
+	reasonJunkRegex       = regexp.MustCompile(`[^A-Za-z0-9\s]`)
+	reasonInwardRegex     = regexp.MustCompile(`^[0-9][ABD-HJLNP-UW-Z]{2}$`)
+	reasonOutwardRegex    = regexp.MustCompile(`^[A-Z][A-Z]?[0-9][A-Z0-9]?$`)
+	reasonSingleAreaRegex = regexp.MustCompile(`^(BR|FY|HA|HD|HG|HR|HS|HX|JE|LD|SM|SR|WC|WN|ZE)[0-9][0-9]$`)
+	reasonDoubleAreaRegex = regexp.MustCompile(`^(AB|LL|SO)[0-9]$`)
+
+	reasonDistrictZeroRegex = regexp.MustCompile(`^[A-Z][A-Z]?0$`)
+	reasonZeroAreaRegex     = regexp.MustCompile(`^(BL|BS|CM|CR|FY|HA|PR|SL|SS)0$`)
+	reasonUndividedRegex    = regexp.MustCompile(`^(EC[1-4]|SW1|W1|WC[0-9])$`)
+	reasonDividedRegex      = regexp.MustCompile(`^((EC[1-4][ABEHMNPRVWXY])|(SW1[ABEHMNPRVWXY])|(W1[A-HJKPSTUW])|(WC[12][ABEHMNPRVWXY])|(E1W)|(N1[CP])|(NW1W)|(SE1P))$`)
+
+	reasonPermissiveInwardRegex = regexp.MustCompile(`^[0-9][A-Z]{2}$`)
+)
+
+// "failureReason" returns the reason an invalid postcode was most likely rejected by the rule profile named
+// "profile" (brief2017 if it has no reasons of its own). REASON_INVALID is returned if no single problem can be
+// found
+func failureReason(postcode, profile string) string {
+	reason := ruleProfiles[profile].failureReason
+	if reason == nil {
+		reason = brief2017FailureReason
+	}
+	return reason(postcode)
+}
+
+// "brief2017FailureReason" returns the reason a postcode was rejected by the Part 1 rules. The checks follow the
+// rules in order, so the first problem found is the one reported
+func brief2017FailureReason(postcode string) string {
+	outward, reason := outwardFailureReason(postcode)
+	if len(reason) > 0 {
+		return reason
+	}
+
+	switch {
+	case reasonSingleAreaRegex.MatchString(outward):
+		return REASON_SINGLE_DIGIT_AREA
+	case reasonDoubleAreaRegex.MatchString(outward):
+		return REASON_DOUBLE_DIGIT_AREA
+	}
+	return REASON_INVALID
+}
+
+// "royalMailFailureReason" returns the reason a postcode was rejected by the current Royal Mail rules: the
+// Part 1 problems, then district 0 outside the areas that use it & the divided central London districts
+func royalMailFailureReason(postcode string) string {
+	outward, reason := outwardFailureReason(postcode)
+	if len(reason) > 0 {
+		return reason
+	}
+
+	last := outward[len(outward)-1]
+	switch {
+	case reasonSingleAreaRegex.MatchString(outward):
+		return REASON_SINGLE_DIGIT_AREA
+	case reasonDoubleAreaRegex.MatchString(outward):
+		return REASON_DOUBLE_DIGIT_AREA
+	case reasonDistrictZeroRegex.MatchString(outward) && !reasonZeroAreaRegex.MatchString(outward):
+		return REASON_DISTRICT_ZERO
+	case reasonUndividedRegex.MatchString(outward):
+		return REASON_DIVIDED_DISTRICT_LETTER
+	case last >= 'A' && last <= 'Z' && !reasonDividedRegex.MatchString(outward):
+		return REASON_DISTRICT_NOT_DIVIDED
+	}
+	return REASON_INVALID
+}
+
+// "permissiveFailureReason" returns the reason a postcode was rejected by the permissive rules, which only check
+// its shape: any case & the space are allowed so only the characters & the inward code can be reported
+func permissiveFailureReason(postcode string) string {
+	trimmed := strings.TrimSpace(postcode)
+	fields := strings.Fields(strings.ToUpper(trimmed))
+
+	switch {
+	case len(trimmed) == 0:
+		return REASON_EMPTY
+	case reasonJunkRegex.MatchString(trimmed):
+		return REASON_JUNK
+	case len(fields) != 2:
+		return REASON_INVALID
+	case len(fields[1]) != 3:
+		return REASON_INWARD_CODE_LENGTH
+	case !reasonPermissiveInwardRegex.MatchString(fields[1]):
+		return REASON_INWARD_CODE
+	}
+	return REASON_INVALID
+}
+
+// "outwardFailureReason" checks "postcode" for the problems the strict profiles share, in the order of the Part 1
+// rules, & returns the first found. If there is none its outward code is returned so the profile can check it
+// against its own rules
+func outwardFailureReason(postcode string) (string, string) {
+	trimmed := strings.TrimSpace(postcode)
+	fields := strings.Fields(trimmed)
+
+	switch {
+	case len(trimmed) == 0:
+		return "", REASON_EMPTY
+	case reasonJunkRegex.MatchString(trimmed):
+		return "", REASON_JUNK
+	case trimmed != strings.ToUpper(trimmed):
+		return "", REASON_LOWER_CASE
+	case len(fields) == 1:
+		return "", REASON_NO_SPACE
+	case len(fields) != 2:
+		return "", REASON_INVALID
+	}
+
+	outward, inward := fields[0], fields[1]
+
+	switch {
+	case len(inward) != 3:
+		return "", REASON_INWARD_CODE_LENGTH
+	case !reasonInwardRegex.MatchString(inward):
+		return "", REASON_INWARD_CODE
+	case outward == "GIR":
+		return "", REASON_INVALID
+	case !reasonOutwardRegex.MatchString(outward):
+		return "", REASON_INVALID
+	case strings.ContainsRune("QVX", rune(outward[0])):
+		return "", REASON_FIRST_POSITION
+	}
+
+	secondIsLetter := outward[1] >= 'A' && outward[1] <= 'Z'
+
+	switch {
+	case secondIsLetter && strings.ContainsRune("IJZ", rune(outward[1])):
+		return "", REASON_SECOND_POSITION
+	case !secondIsLetter && len(outward) == 3 && outward[2] >= 'A' && !strings.ContainsRune("ABCDEFGHJKPSTUW", rune(outward[2])):
+		return "", REASON_THIRD_POSITION
+	case secondIsLetter && len(outward) == 4 && outward[3] >= 'A' && !strings.ContainsRune("ABEHMNPRVWXY", rune(outward[3])):
+		return "", REASON_FOURTH_POSITION
+	}
+	return outward, ""
+}
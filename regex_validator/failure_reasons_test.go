@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// expected: the reason given for each invalid postcode in the Part 1 table matches its "Expected problem", or
+// for "XX XXX" (which the table only calls "Invalid") the inward code that makes it invalid
+func Test_failureReason__Part1Postcodes(t *testing.T) {
+
+	testCases := []struct {
+		postcode string
+		expected string
+	}{
+		{"$%± ()()", REASON_JUNK},
+		{"XX XXX", REASON_INWARD_CODE},
+		{"A1 9A", REASON_INWARD_CODE_LENGTH},
+		{"LS44PL", REASON_NO_SPACE},
+		{"Q1A 9AA", REASON_FIRST_POSITION},
+		{"V1A 9AA", REASON_FIRST_POSITION},
+		{"X1A 9BB", REASON_FIRST_POSITION},
+		{"LI10 3QP", REASON_SECOND_POSITION},
+		{"LJ10 3QP", REASON_SECOND_POSITION},
+		{"LZ10 3QP", REASON_SECOND_POSITION},
+		{"A9Q 9AA", REASON_THIRD_POSITION},
+		{"AA9C 9AA", REASON_FOURTH_POSITION},
+		{"FY10 4PL", REASON_SINGLE_DIGIT_AREA},
+		{"SO1 4QQ", REASON_DOUBLE_DIGIT_AREA},
+		{"", REASON_EMPTY},
+		{"ls4 4pl", REASON_LOWER_CASE},
+	}
+
+	for _, element := range testCases {
+		result := failureReason(element.postcode, PROFILE_BRIEF_2017)
+
+		if result != element.expected {
+			error := fmt.Sprintf("Given postcode: %s, Expected: %s   got: %s", element.postcode, element.expected, result)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: the reason each rule profile gives for postcodes it rejects, including those the other profiles accept
+func Test_failureReason__EachProfile(t *testing.T) {
+
+	testCases := []struct {
+		profile  string
+		postcode string
+		expected string
+	}{
+		{PROFILE_ROYAL_MAIL_CURRENT, "LS44PL", REASON_NO_SPACE},
+		{PROFILE_ROYAL_MAIL_CURRENT, "Q1A 9AA", REASON_FIRST_POSITION},
+		{PROFILE_ROYAL_MAIL_CURRENT, "FY10 4PL", REASON_SINGLE_DIGIT_AREA},
+		{PROFILE_ROYAL_MAIL_CURRENT, "M0 1AA", REASON_DISTRICT_ZERO},
+		{PROFILE_ROYAL_MAIL_CURRENT, "LS0 1AA", REASON_DISTRICT_ZERO},
+		{PROFILE_ROYAL_MAIL_CURRENT, "SW1 1AA", REASON_DIVIDED_DISTRICT_LETTER},
+		{PROFILE_ROYAL_MAIL_CURRENT, "WC1 1AA", REASON_DIVIDED_DISTRICT_LETTER},
+		{PROFILE_ROYAL_MAIL_CURRENT, "M1A 1AA", REASON_DISTRICT_NOT_DIVIDED},
+		{PROFILE_ROYAL_MAIL_CURRENT, "E2W 1AA", REASON_DISTRICT_NOT_DIVIDED},
+		{PROFILE_ROYAL_MAIL_CURRENT, "WC3A 1AA", REASON_DISTRICT_NOT_DIVIDED},
+		{PROFILE_ROYAL_MAIL_CURRENT_NON_GEOGRAPHIC, "M0 1AA", REASON_DISTRICT_ZERO},
+		{PROFILE_PERMISSIVE, "", REASON_EMPTY},
+		{PROFILE_PERMISSIVE, "$%± ()()", REASON_JUNK},
+		{PROFILE_PERMISSIVE, "A1 9A", REASON_INWARD_CODE_LENGTH},
+		{PROFILE_PERMISSIVE, "ls4 4p1", REASON_INWARD_CODE},
+		{PROFILE_PERMISSIVE, "XX XXX", REASON_INWARD_CODE},
+		{PROFILE_PERMISSIVE, "ABC1 1AA", REASON_INVALID},
+		{PROFILE_BRIEF_2017_NON_GEOGRAPHIC, "AA9C 9AA", REASON_FOURTH_POSITION},
+	}
+
+	for _, element := range testCases {
+		validator, err := createRegexValidatorGroupForProfile(element.profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recVal := NewRecordValidator(validator, nil, nil)
+		recVal.SetRuleProfile(element.profile)
+
+		rec := &ImportRecord{postcode: element.postcode}
+		recVal.Validate(rec)
+
+		if rec.isValid || rec.reason != element.expected {
+			error := fmt.Sprintf("Given profile: %s & postcode: %s, Expected: invalid %s   got: %t %s", element.profile, element.postcode, element.expected, rec.isValid, rec.reason)
+			t.Error(error)
+		}
+	}
+}
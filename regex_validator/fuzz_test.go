@@ -0,0 +1,259 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// Fuzz targets for the validators and the line parser, each is checked against a reference oracle that is
+// written without regexs or the strconv/strings functions used by the code under test. The seed corpus is
+// built from the Part 1 table. Run one with: go test -fuzz=Fuzz_GroupIsStringValid
+
+// ---------------------------- reference oracles ------------------------------------------------------
+
+// "isRegexSpace" reports whether "c" is matched by \s in Go's regexp package
+func isRegexSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// "inClass" reports whether "c" is one of the characters in "class"
+func inClass(c byte, class string) bool {
+	for i := 0; i < len(class); i++ {
+		if class[i] == c {
+			return true
+		}
+	}
+	return false
+}
+
+// character classes used by the brief's regex, written out in full
+const (
+	classDigit  = "0123456789"
+	classFirst  = "ABCDEFGHIJKLMNOPRSTUWYZ" // [A-PR-UWYZ]
+	classSecond = "ABCDEFGHKLMNOPQRSTUVWXY" // [A-HK-Y]
+	classThird  = "ABCDEFGHJKPSTUW"         // [A-HJKPSTUW]
+	classFourth = "ABEHMNPRVWXY"            // [ABEHMNPRVWXY]
+	classUnit   = "ABDEFGHJLNPQRSTUWXYZ"    // [ABD-HJLNP-UW-Z]
+	classUpper  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+// "matchesClasses" reports whether the bytes of "s" starting at "start" match each class in turn
+func matchesClasses(s string, start int, classes ...string) bool {
+	if start < 0 || start+len(classes) > len(s) {
+		return false
+	}
+	for i, class := range classes {
+		if !inClass(s[start+i], class) {
+			return false
+		}
+	}
+	return true
+}
+
+// "referenceBriefIsValid" is an oracle for the brief2017 RegexValidatorGroup. It follows the same unanchored
+// search semantics: the main pattern may match anywhere (only the A9/A99 & A9A prefixes are anchored to the
+// start of the string) and neither exclusion pattern may match anywhere
+func referenceBriefIsValid(s string) bool {
+	mainMatch := false
+	excluded := false
+
+	for i := 0; i+4 <= len(s); i++ {
+		// "GIR 0AA" may be found anywhere
+		if i+7 <= len(s) && s[i:i+3] == "GIR" && isRegexSpace(s[i+3]) && s[i+4:i+7] == "0AA" {
+			mainMatch = true
+		}
+
+		// look for an inward code "\s9AA" starting at "i", then check the outward code that ends at "i"
+		if !isRegexSpace(s[i]) || !matchesClasses(s, i+1, classDigit, classUnit, classUnit) {
+			continue
+		}
+
+		switch {
+		case i == 2 && matchesClasses(s, 0, classFirst, classDigit):
+			mainMatch = true
+		case i == 3 && matchesClasses(s, 0, classFirst, classDigit, classDigit):
+			mainMatch = true
+		case i == 3 && matchesClasses(s, 0, classFirst, classDigit, classThird):
+			mainMatch = true
+		case matchesClasses(s, i-4, classFirst, classSecond, classDigit, classDigit):
+			mainMatch = true
+		case matchesClasses(s, i-3, classFirst, classSecond, classDigit):
+			mainMatch = true
+		case matchesClasses(s, i-4, "W", "C", classDigit, classUpper):
+			mainMatch = true
+		case matchesClasses(s, i-4, classFirst, classSecond, classDigit, classFourth):
+			mainMatch = true
+		}
+
+		// AA99 exclusion: an area with only single digit districts followed by two digits
+		if i >= 4 && matchesClasses(s, i-2, classDigit, classDigit) {
+			switch s[i-4 : i-2] {
+			case "BR", "FY", "HA", "HD", "HG", "HR", "HS", "HX", "JE", "LD", "SM", "SR", "WC", "WN", "ZE":
+				excluded = true
+			}
+		}
+
+		// AA9 exclusion: an area with only double digit districts followed by one digit
+		if i >= 3 && matchesClasses(s, i-1, classDigit) {
+			switch s[i-3 : i-1] {
+			case "AB", "LL", "SO":
+				excluded = true
+			}
+		}
+	}
+
+	return mainMatch && !excluded
+}
+
+// "referenceRowIdIsValid" is an oracle for parseRowId with ROW_ID_RANGE_DEFAULT: an optional sign followed by
+// at least one digit, with a value that is not negative and fits in an int64 ("-0" is allowed)
+func referenceRowIdIsValid(s string) bool {
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		negative := s[0] == '-'
+		s = s[1:]
+		if negative {
+			// only a negative zero is allowed
+			for i := 0; i < len(s); i++ {
+				if s[i] != '0' {
+					return false
+				}
+			}
+			return len(s) > 0
+		}
+	}
+	if len(s) == 0 {
+		return false
+	}
+
+	const maxInt64 = "9223372036854775807"
+	for i := 0; i < len(s); i++ {
+		if !inClass(s[i], classDigit) {
+			return false
+		}
+	}
+
+	// compare the digits (without leading zeros) to the largest int64
+	for len(s) > 1 && s[0] == '0' {
+		s = s[1:]
+	}
+	return len(s) < len(maxInt64) || (len(s) == len(maxInt64) && s <= maxInt64)
+}
+
+// "referenceTrim" removes leading & trailing white space the same way strings.TrimSpace does for ASCII
+// input, other input is passed to strings.TrimSpace as unicode white space is not worth rewriting here
+func referenceTrim(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return strings.TrimSpace(s)
+		}
+	}
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r' }
+	start, end := 0, len(s)
+	for start < end && isSpace(s[start]) {
+		start++
+	}
+	for end > start && isSpace(s[end-1]) {
+		end--
+	}
+	return s[start:end]
+}
+
+// ---------------------------- fuzz targets -----------------------------------------------------------
+
+// the postcodes from the Part 1 table along with a few other shapes, used as the seed corpus
+func postcodeSeedCorpus() []string {
+	seeds := []string{"", " ", "GIR 0AA", "XGIR 0AAX", "EC1A 1BB\n", "AB12 3CD", "1AB12 3CD", "WC1 1AA", "SO10 9AAX"}
+	for _, element := range brief2017ConformanceTable {
+		seeds = append(seeds, element.postcode)
+	}
+	return seeds
+}
+
+// expected: GroupIsStringValid never panics & agrees with referenceBriefIsValid
+func Fuzz_GroupIsStringValid(f *testing.F) {
+	for _, seed := range postcodeSeedCorpus() {
+		f.Add(seed)
+	}
+
+	validator := createMainRegexValidatorGroup()
+
+	f.Fuzz(func(t *testing.T, postcode string) {
+		result := validator.GroupIsStringValid(postcode)
+		expected := referenceBriefIsValid(postcode)
+
+		if result != expected {
+			t.Errorf("Given postcode: %q, Expected: %t   got: %t", postcode, expected, result)
+		}
+	})
+}
+
+// expected: NewImportRecordInRange never panics, accepts exactly the row ids the oracle accepts & keeps the postcode
+func Fuzz_NewImportRecord(f *testing.F) {
+	for i, seed := range postcodeSeedCorpus() {
+		f.Add("1064397", seed)
+		f.Add([]string{"-1", "+7", "2147483648", "9223372036854775808", "00", "-0", "1e5", " 1"}[i%8], seed)
+	}
+
+	f.Fuzz(func(t *testing.T, rowId, postcode string) {
+		rec, err := NewImportRecordInRange([]string{rowId, postcode}, ROW_ID_RANGE_DEFAULT)
+		expected := referenceRowIdIsValid(rowId)
+
+		if (err == nil) != expected {
+			t.Fatalf("Given row id: %q, Expected valid: %t   got error: %v", rowId, expected, err)
+		}
+		if err == nil && (rec.postcode != postcode || rec.isValid) {
+			t.Errorf("Given row id: %q & postcode: %q, got record: %+v", rowId, postcode, rec)
+		}
+	})
+}
+
+// expected: the csv input format's split never panics & returns the trimmed text before the first comma and
+// between the first & second commas, or an error if there is no comma. Lines with quotes are only checked for the last
+func Fuzz_csvInputFormat_split(f *testing.F) {
+	format := inputFormats[FORMAT_CSV](JsonFieldNames{})
+
+	for i, seed := range postcodeSeedCorpus() {
+		f.Add(string(rune('0'+i%10)) + "," + seed + "\n")
+	}
+	f.Add("row_id,postcode\r\n")
+	f.Add("1,AB1 2CD,extra\n")
+	f.Add("no comma\n")
+
+	f.Fuzz(func(t *testing.T, line string) {
+		result, err := format.split(line)
+		ok := err == nil
+
+		first := -1
+		for i := 0; i < len(line); i++ {
+			if line[i] == ',' {
+				first = i
+				break
+			}
+		}
+		if first == -1 {
+			if ok {
+				t.Fatalf("Given line: %q, Expected: no fields   got: %q", line, result)
+			}
+			return
+		}
+		for i := 0; i < len(line); i++ {
+			if line[i] == '"' {
+				return
+			}
+		}
+
+		end := len(line)
+		for i := first + 1; i < len(line); i++ {
+			if line[i] == ',' {
+				end = i
+				break
+			}
+		}
+
+		expected := []string{referenceTrim(line[:first]), referenceTrim(line[first+1 : end])}
+		if !ok || len(result) != 2 || result[0] != expected[0] || result[1] != expected[1] {
+			t.Errorf("Given line: %q, Expected: %q   got: %q (ok: %t)", line, expected, result, ok)
+		}
+	})
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// the name of the subcommand that writes a synthetic import file
+const GENERATE_COMMAND = "generate"
+
+// the quoting styles a generated file can use
+const (
+	QUOTING_NONE     = "none"     // no field is quoted
+	QUOTING_POSTCODE = "postcode" // only the postcode is quoted
+	QUOTING_ALL      = "all"      // both fields are quoted
+	QUOTING_MIXED    = "mixed"    // each line uses one of the styles above at random
+)
+
+// the default values of the generator's flags
+const (
+	GENERATE_ROWS_DEFAULT      int     = 100000
+	GENERATE_SEED_DEFAULT      int64   = 2017
+	GENERATE_VALID_DEFAULT     float64 = 0.9
+	GENERATE_MALFORMED_DEFAULT float64 = 0
+	GENERATE_OUT_DEFAULT               = "generated_import_data.csv"
+)
+
+// the categories of invalid postcode the generator writes, one for each problem in the Part 1 table
+var generatedInvalidCategories = []string{
+	REASON_JUNK, REASON_INWARD_CODE, REASON_INWARD_CODE_LENGTH, REASON_NO_SPACE, REASON_FIRST_POSITION,
+	REASON_SECOND_POSITION, REASON_THIRD_POSITION, REASON_FOURTH_POSITION, REASON_SINGLE_DIGIT_AREA,
+	REASON_DOUBLE_DIGIT_AREA,
+}
+
+// type that stores the options used to generate an import file
+type GeneratorOptions struct {
+	rows           int
+	seed           int64
+	validRatio     float64 // the share of well formed rows that hold a valid postcode
+	malformedRatio float64 // the share of rows that are not a "row_id,postcode" pair at all
+	shuffle        bool    // write the row ids in a random order rather than counting up from 1
+	quoting        string
+}
+
+// type that creates the rows of a synthetic import file, the same options & seed always give the same rows
+type ImportDataGenerator struct {
+	opts      GeneratorOptions
+	rnd       *rand.Rand
+	validator *RegexValidatorGroup
+}
+
+// create and return a pointer to a new ImportDataGenerator
+func NewImportDataGenerator(opts GeneratorOptions) *ImportDataGenerator {
+	return &ImportDataGenerator{opts: opts, rnd: rand.New(rand.NewSource(opts.seed)), validator: createMainRegexValidatorGroup()}
+}
+
+// "runGenerateCommand" parses the flags of the generate subcommand and writes the import file it describes
+func runGenerateCommand(args []string) {
+	flags := flag.NewFlagSet(GENERATE_COMMAND, flag.ExitOnError)
+
+	opts := GeneratorOptions{}
+	var path string
+	flags.IntVar(&opts.rows, "rows", GENERATE_ROWS_DEFAULT, "the number of rows to write, not counting the header")
+	flags.Int64Var(&opts.seed, "seed", GENERATE_SEED_DEFAULT, "the seed of the random number generator, the same seed always writes the same file")
+	flags.Float64Var(&opts.validRatio, "valid", GENERATE_VALID_DEFAULT, "the share (0 to 1) of well formed rows that hold a valid postcode, the rest are spread over each Part 1 invalid category")
+	flags.Float64Var(&opts.malformedRatio, "malformed", GENERATE_MALFORMED_DEFAULT, "the share (0 to 1) of rows that are malformed (no comma, a row id that is not a number, blank, which is only malformed with -blank-lines error), import the file with -malformed-rows skip to get past them")
+	flags.BoolVar(&opts.shuffle, "shuffle", true, "write the row ids in a random order")
+	flags.StringVar(&opts.quoting, "quoting", QUOTING_NONE, "how fields are quoted, one of: none, postcode, all, mixed")
+	flags.StringVar(&path, "out", GENERATE_OUT_DEFAULT, "the location of the .csv file to write")
+	flags.Parse(args)
+
+	if err := checkGeneratorOptions(opts); err != nil {
+		errorExit(err.Error(), exitCodeForError(err))
+	}
+	if err := writeImportDataFile(path, opts); err != nil {
+		errorExit(err.Error(), exitCodeForError(err))
+	}
+}
+
+// "checkGeneratorOptions" returns an error, a usage error, if a flag of the generate subcommand is not valid
+func checkGeneratorOptions(opts GeneratorOptions) error {
+	if opts.rows < 0 || opts.validRatio < 0 || opts.validRatio > 1 || opts.malformedRatio < 0 || opts.malformedRatio > 1 {
+		return errors.New("-rows must not be negative, -valid & -malformed must be between 0 and 1")
+	}
+	switch opts.quoting {
+	case QUOTING_NONE, QUOTING_POSTCODE, QUOTING_ALL, QUOTING_MIXED:
+		return nil
+	}
+	return fmt.Errorf("Unknown quoting style \"%s\", must be one of: none, postcode, all, mixed", opts.quoting)
+}
+
+// "writeImportDataFile" writes the import file described by "opts" to "path", an OutputError is returned if the
+// file can not be created or written
+func writeImportDataFile(path string, opts GeneratorOptions) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return &OutputError{path: path, err: err}
+	}
+
+	writer := bufio.NewWriter(file)
+	err = NewImportDataGenerator(opts).Write(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return &OutputError{path: path, err: err}
+	}
+	return nil
+}
+
+// "Write" writes the header & every row of the import file to "w"
+func (g *ImportDataGenerator) Write(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "row_id,postcode"); err != nil {
+		return err
+	}
+
+	// the row ids are 1 to rows, in a random order if asked for
+	ids := make([]int, g.opts.rows)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+	if g.opts.shuffle {
+		g.rnd.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+	}
+
+	for _, id := range ids {
+		if _, err := fmt.Fprintln(w, g.line(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// "line" returns a single generated row (without its line ending)
+func (g *ImportDataGenerator) line(id int) string {
+	if g.rnd.Float64() < g.opts.malformedRatio {
+		return g.malformedLine(id)
+	}
+
+	var postcode string
+	if g.rnd.Float64() < g.opts.validRatio {
+		postcode = g.validPostcode()
+	} else {
+		postcode = g.invalidPostcode(generatedInvalidCategories[g.rnd.Intn(len(generatedInvalidCategories))])
+	}
+
+	rowId := strconv.Itoa(id)
+	quoting := g.opts.quoting
+	if quoting == QUOTING_MIXED {
+		quoting = []string{QUOTING_NONE, QUOTING_POSTCODE, QUOTING_ALL}[g.rnd.Intn(3)]
+	}
+
+	switch quoting {
+	case QUOTING_POSTCODE:
+		return rowId + "," + quoteField(postcode)
+	case QUOTING_ALL:
+		return quoteField(rowId) + "," + quoteField(postcode)
+	}
+	return rowId + "," + postcode
+}
+
+// "quoteField" puts double quotes around "str", doubling any quotes inside it
+func quoteField(str string) string {
+	return `"` + strings.Replace(str, `"`, `""`, -1) + `"`
+}
+
+// "malformedLine" returns a row that is not a "row_id,postcode" pair
+func (g *ImportDataGenerator) malformedLine(id int) string {
+	switch g.rnd.Intn(4) {
+	case 0:
+		return strconv.Itoa(id) + " " + g.validPostcode()
+	case 1:
+		return "row" + strconv.Itoa(id) + "," + g.validPostcode()
+	case 2:
+		return ""
+	}
+	return "," + g.validPostcode()
+}
+
+// character classes used to build postcodes
+const (
+	genFirst   = "ABCDEFGHIJKLMNOPRSTUWYZ"
+	genSecond  = "ABCDEFGHKLMNOPQRSTUVWXY"
+	genThird   = "ABCDEFGHJKPSTUW"
+	genFourth  = "ABEHMNPRVWXY"
+	genUnit    = "ABDEFGHJLNPQRSTUWXYZ"
+	genDigit   = "0123456789"
+	genLetters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+// "pick" returns a random character from "chars"
+func (g *ImportDataGenerator) pick(chars string) string {
+	return string(chars[g.rnd.Intn(len(chars))])
+}
+
+// "inward" returns a random valid inward code
+func (g *ImportDataGenerator) inward() string {
+	return g.pick(genDigit) + g.pick(genUnit) + g.pick(genUnit)
+}
+
+// "validPostcode" returns a random postcode of a random shape that the brief2017 rules find valid
+func (g *ImportDataGenerator) validPostcode() string {
+	for {
+		var outward string
+		switch g.rnd.Intn(7) {
+		case 0:
+			outward = g.pick(genFirst) + g.pick(genDigit)
+		case 1:
+			outward = g.pick(genFirst) + g.pick(genDigit) + g.pick(genDigit)
+		case 2:
+			outward = g.pick(genFirst) + g.pick(genSecond) + g.pick(genDigit)
+		case 3:
+			outward = g.pick(genFirst) + g.pick(genSecond) + g.pick(genDigit) + g.pick(genDigit)
+		case 4:
+			outward = g.pick(genFirst) + g.pick(genDigit) + g.pick(genThird)
+		case 5:
+			outward = g.pick(genFirst) + g.pick(genSecond) + g.pick(genDigit) + g.pick(genFourth)
+		case 6:
+			outward = "WC" + g.pick(genDigit) + g.pick(genLetters)
+		}
+
+		postcode := outward + " " + g.inward()
+		if g.validator.GroupIsStringValid(postcode) {
+			return postcode
+		}
+	}
+}
+
+// "invalidPostcode" returns a random postcode that has the problem named by "category" (one of the REASON_
+// constants in generatedInvalidCategories), it is always found invalid by the brief2017 rules
+func (g *ImportDataGenerator) invalidPostcode(category string) string {
+	for {
+		var postcode string
+		switch category {
+		case REASON_JUNK:
+			postcode = g.pick("$%^()!@#*&") + g.pick("$%^()!@#*&") + " " + g.pick("()[]{}<>") + g.pick("()[]{}<>")
+		case REASON_INWARD_CODE:
+			postcode = g.pick(genLetters) + g.pick(genLetters) + " " + g.pick(genLetters) + g.pick(genLetters) + g.pick(genLetters)
+		case REASON_INWARD_CODE_LENGTH:
+			postcode = g.pick(genFirst) + g.pick(genDigit) + " " + g.pick(genDigit) + g.pick(genUnit)
+		case REASON_NO_SPACE:
+			postcode = strings.Replace(g.validPostcode(), " ", "", 1)
+		case REASON_FIRST_POSITION:
+			postcode = g.pick("QVX") + g.pick(genDigit) + g.pick(genThird) + " " + g.inward()
+		case REASON_SECOND_POSITION:
+			postcode = g.pick(genFirst) + g.pick("IJZ") + g.pick(genDigit) + g.pick(genDigit) + " " + g.inward()
+		case REASON_THIRD_POSITION:
+			postcode = g.pick(genFirst) + g.pick(genDigit) + g.pick("ILMNOQRVXYZ") + " " + g.inward()
+		case REASON_FOURTH_POSITION:
+			postcode = g.pick(genFirst) + g.pick(genSecond) + g.pick(genDigit) + g.pick("CDFGIJKLOQSTUZ") + " " + g.inward()
+		case REASON_SINGLE_DIGIT_AREA:
+			postcode = g.choose("BR", "FY", "HA", "HD", "HG", "HR", "HS", "HX", "JE", "LD", "SM", "SR", "WN", "ZE") + g.pick(genDigit) + g.pick(genDigit) + " " + g.inward()
+		case REASON_DOUBLE_DIGIT_AREA:
+			postcode = g.choose("AB", "LL", "SO") + g.pick(genDigit) + " " + g.inward()
+		default:
+			panic("unknown invalid postcode category: " + category)
+		}
+
+		if !g.validator.GroupIsStringValid(postcode) {
+			return postcode
+		}
+	}
+}
+
+// "choose" returns one of "choices" at random
+func (g *ImportDataGenerator) choose(choices ...string) string {
+	return choices[g.rnd.Intn(len(choices))]
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// "generateLines" generates an import file with "opts" and returns its lines without the header
+func generateLines(t *testing.T, opts GeneratorOptions) []string {
+	var buf bytes.Buffer
+	if err := NewImportDataGenerator(opts).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if lines[0] != "row_id,postcode" {
+		t.Fatalf("Given options: %+v, Expected header: row_id,postcode   got: %s", opts, lines[0])
+	}
+	return lines[1:]
+}
+
+// expected: the same options & seed write the same file, another seed writes a different file
+func Test_ImportDataGenerator__Deterministic(t *testing.T) {
+	opts := GeneratorOptions{rows: 500, seed: 7, validRatio: 0.5, malformedRatio: 0.1, shuffle: true, quoting: QUOTING_MIXED}
+
+	first := strings.Join(generateLines(t, opts), "\n")
+	second := strings.Join(generateLines(t, opts), "\n")
+	if first != second {
+		t.Error("Given the same options twice, Expected: the same file   got: different files")
+	}
+
+	opts.seed = 8
+	if other := strings.Join(generateLines(t, opts), "\n"); other == first {
+		t.Error("Given two different seeds, Expected: different files   got: the same file")
+	}
+}
+
+// expected: one row for each row id from 1 to rows, in order unless shuffled
+func Test_ImportDataGenerator__RowIds(t *testing.T) {
+	format := inputFormats[FORMAT_CSV](JsonFieldNames{})
+	for _, shuffle := range []bool{false, true} {
+		lines := generateLines(t, GeneratorOptions{rows: 1000, seed: 1, validRatio: 0.9, shuffle: shuffle, quoting: QUOTING_NONE})
+
+		seen := make(map[uint64]bool)
+		inOrder := true
+		for i, line := range lines {
+			fields, err := format.split(line)
+			if err != nil {
+				t.Fatalf("Given line: %q, Expected: a row_id,postcode pair", line)
+			}
+			rowId, err := parseRowId(fields[0], ROW_ID_RANGE_DEFAULT)
+			if err != nil || rowId < 1 || rowId > 1000 || seen[rowId] {
+				t.Fatalf("Given line: %q, Expected: a new row id from 1 to 1000   got error: %v", line, err)
+			}
+			seen[rowId] = true
+			inOrder = inOrder && rowId == uint64(i+1)
+		}
+
+		if len(seen) != 1000 || inOrder == shuffle {
+			error := fmt.Sprintf("Given shuffle: %t, Expected: 1000 rows in order: %t   got: %d rows in order: %t", shuffle, !shuffle, len(seen), inOrder)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: every valid postcode passes & every invalid postcode fails the brief2017 rules for its category
+func Test_ImportDataGenerator__Categories(t *testing.T) {
+	gen := NewImportDataGenerator(GeneratorOptions{seed: 3})
+	validator := createMainRegexValidatorGroup()
+
+	for i := 0; i < 1000; i++ {
+		if postcode := gen.validPostcode(); !validator.GroupIsStringValid(postcode) {
+			t.Errorf("Given a generated valid postcode: %s, Expected: valid   got: invalid", postcode)
+		}
+	}
+
+	for _, category := range generatedInvalidCategories {
+		for i := 0; i < 200; i++ {
+			postcode := gen.invalidPostcode(category)
+			if validator.GroupIsStringValid(postcode) {
+				t.Errorf("Given a generated %s postcode: %s, Expected: invalid   got: valid", category, postcode)
+			}
+			if reason := failureReason(postcode, PROFILE_BRIEF_2017); reason != category {
+				error := fmt.Sprintf("Given a generated %s postcode: %s, Expected reason: %s   got: %s", category, postcode, category, reason)
+				t.Error(error)
+			}
+		}
+	}
+}
+
+// expected: the share of valid, invalid & malformed rows follows the ratios given
+func Test_ImportDataGenerator__Mix(t *testing.T) {
+	lines := generateLines(t, GeneratorOptions{rows: 10000, seed: 5, validRatio: 0.8, malformedRatio: 0.05, quoting: QUOTING_NONE})
+	validator := createMainRegexValidatorGroup()
+	format := inputFormats[FORMAT_CSV](JsonFieldNames{})
+
+	numValid, numInvalid, numMalformed := 0, 0, 0
+	for _, line := range lines {
+		fields, err := format.split(line)
+		if err == nil {
+			_, err = parseRowId(fields[0], ROW_ID_RANGE_DEFAULT)
+		}
+
+		switch {
+		case err != nil:
+			numMalformed++
+		case validator.GroupIsStringValid(fields[1]):
+			numValid++
+		default:
+			numInvalid++
+		}
+	}
+
+	// allow for the randomness, each expected count is well inside these bounds for any seed
+	if numMalformed < 400 || numMalformed > 600 || numValid < 7300 || numValid > 7900 || numInvalid < 1600 || numInvalid > 2200 {
+		error := fmt.Sprintf("Given 10000 rows with valid: 0.8 & malformed: 0.05, Expected: about 7600 valid, 1900 invalid & 500 malformed   got: %d, %d & %d", numValid, numInvalid, numMalformed)
+		t.Error(error)
+	}
+}
+
+// expected: a file written with each quoting style is read by the importer with the valid & invalid rows in the
+// ratio asked for, every row when only valid or only invalid postcodes are written
+func Test_ImportDataGenerator__Quoting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quoting")
+	check(err)
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		validRatio float64
+		minValid   int
+		maxValid   int
+	}{
+		{1, 2000, 2000},
+		{0, 0, 0},
+		{0.7, 1300, 1500},
+	}
+
+	for _, quoting := range []string{QUOTING_NONE, QUOTING_POSTCODE, QUOTING_ALL, QUOTING_MIXED} {
+		for _, element := range testCases {
+			opts := GeneratorOptions{rows: 2000, seed: 9, validRatio: element.validRatio, quoting: quoting}
+			path := filepath.Join(dir, "generated_import_data.csv")
+			check(ioutil.WriteFile(path, []byte("row_id,postcode\n"+strings.Join(generateLines(t, opts), "\n")+"\n"), 0644))
+
+			result, err := runImport(time.Now(), newDefaultProgramOptions(path, dir))
+			if err != nil || result.numValid < element.minValid || result.numValid > element.maxValid || result.numValid+result.numInvalid != 2000 {
+				error := fmt.Sprintf("Given quoting: %s & valid: %.1f, Expected: %d to %d of 2000 rows valid   got: %d valid, %d invalid (error: %v)",
+					quoting, element.validRatio, element.minValid, element.maxValid, result.numValid, result.numInvalid, err)
+				t.Error(error)
+			}
+		}
+	}
+}
+
+// expected: a file generated with malformed rows stops a default import with a ParseError, & is imported with
+// -malformed-rows skip with every well formed row validated & every other row counted as malformed (blank lines
+// too with -blank-lines error), whether or not checkpoints are written
+func Test_ImportDataGenerator__ImportMalformed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "malformed")
+	check(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, GENERATE_OUT_DEFAULT)
+	check(writeImportDataFile(path, GeneratorOptions{rows: 2000, seed: 11, validRatio: 0.8, malformedRatio: 0.1, shuffle: true, quoting: QUOTING_MIXED}))
+
+	// a well formed row starts with its row id, which may be quoted, & a comma
+	data, err := ioutil.ReadFile(path)
+	check(err)
+	wellFormed := regexp.MustCompile(`^"?[0-9]+"?,`)
+	numWellFormed, numBlank := 0, 0
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")[1:] {
+		if wellFormed.MatchString(line) {
+			numWellFormed++
+		} else if len(line) == 0 {
+			numBlank++
+		}
+	}
+	if numWellFormed == 2000 || numBlank == 0 {
+		t.Fatalf("Given malformed: 0.1, Expected: malformed & blank rows   got: %d well formed & %d blank of 2000", numWellFormed, numBlank)
+	}
+
+	if _, err := runImport(time.Now(), newDefaultProgramOptions(path, dir)); exitCodeForError(err) != EXIT_MALFORMED {
+		error := fmt.Sprintf("Given a generated file with malformed rows, Expected: exit code %d   got: %v", EXIT_MALFORMED, err)
+		t.Error(error)
+	}
+
+	testCases := []struct {
+		blankLines      string
+		checkpointEvery int
+		numMalformed    int
+	}{
+		{BLANK_LINES_SKIP, 0, 2000 - numWellFormed - numBlank},
+		{BLANK_LINES_ERROR, 0, 2000 - numWellFormed},
+		{BLANK_LINES_ERROR, 300, 2000 - numWellFormed},
+	}
+
+	for _, element := range testCases {
+		opts := newDefaultProgramOptions(path, dir)
+		opts.malformedRows = MALFORMED_ROWS_SKIP
+		opts.blankLines = element.blankLines
+		opts.checkpointEvery = element.checkpointEvery
+
+		result, err := runImport(time.Now(), opts)
+		if err != nil || result.numValid+result.numInvalid != numWellFormed || result.numMalformed != element.numMalformed {
+			error := fmt.Sprintf("Given -blank-lines %s & -checkpoint %d, Expected: %d rows validated & %d malformed   got: %d valid, %d invalid & %d malformed (error: %v)",
+				element.blankLines, element.checkpointEvery, numWellFormed, element.numMalformed, result.numValid, result.numInvalid, result.numMalformed, err)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: flags that are not valid are a usage error & a file that can not be created is an OutputError
+func Test_runGenerateCommand__Errors(t *testing.T) {
+	badOptions := []GeneratorOptions{
+		{rows: -1, validRatio: 0.9, quoting: QUOTING_NONE},
+		{rows: 10, validRatio: 1.5, quoting: QUOTING_NONE},
+		{rows: 10, validRatio: 0.9, malformedRatio: -0.1, quoting: QUOTING_NONE},
+		{rows: 10, validRatio: 0.9, quoting: "single"},
+	}
+	for _, opts := range badOptions {
+		if err := checkGeneratorOptions(opts); exitCodeForError(err) != EXIT_USAGE {
+			error := fmt.Sprintf("Given options: %+v, Expected exit code: %d   got: %d (%v)", opts, EXIT_USAGE, exitCodeForError(err), err)
+			t.Error(error)
+		}
+	}
+
+	opts := GeneratorOptions{rows: 10, validRatio: 0.9, quoting: QUOTING_NONE}
+	if err := checkGeneratorOptions(opts); err != nil {
+		error := fmt.Sprintf("Given options: %+v, Expected: no error   got: %v", opts, err)
+		t.Error(error)
+	}
+
+	path := filepath.Join(os.TempDir(), "no such directory", "generated_import_data.csv")
+	err := writeImportDataFile(path, opts)
+	if _, ok := err.(*OutputError); !ok || exitCodeForError(err) != EXIT_IO_FAILURE {
+		error := fmt.Sprintf("Given path: %s, Expected: an OutputError   got: %v", path, err)
+		t.Error(error)
+	}
+}
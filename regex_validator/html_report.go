@@ -0,0 +1,175 @@
+package main
+
+import (
+	"html/template"
+	"os"
+	"sort"
+	"time"
+)
+
+// the number of invalid rows shown for each failure reason in the html report
+const HTML_REPORT_SAMPLES_PER_REASON int = 5
+
+// type that stores the metadata of an input or output file shown in the html report
+type ReportFileInfo struct {
+	Role     string
+	Path     string
+	Size     int64
+	Modified time.Time
+	Missing  bool
+}
+
+// type that stores a row shown as a sample in the html report
+type ReportSample struct {
+	RowId    uint64
+	Postcode string
+}
+
+// type that stores a failure reason, how often it was seen & a few of the rows that failed for that reason
+type ReportReason struct {
+	Reason  string
+	Count   uint64
+	Percent float64
+	Samples []ReportSample
+}
+
+// type that stores everything shown in the html report
+type HtmlReport struct {
+	Generated  time.Time
+	Profile    string
+	Total      int
+	Succeeded  int
+	Failed     int
+	Took       time.Duration
+	Speed      float64
+	Reasons    []ReportReason
+	Areas      []StatisticsCount
+	Files      []ReportFileInfo
+	TopInvalid []StatisticsCount
+}
+
+// "newHtmlReport" gathers the data for the html report, the same data as "printCompletionReport" along with
+// the failure reasons (with sample rows), per area counts & the metadata of the input and output files
+func newHtmlReport(startTime time.Time, profile string, invalidRecs ImportRecordGroup, numValid int, summary StatisticsSummary, files map[string]string) *HtmlReport {
+	elapsed := time.Since(startTime)
+
+	report := &HtmlReport{
+		Generated:  time.Now(),
+		Profile:    profile,
+		Total:      numValid + len(invalidRecs),
+		Succeeded:  numValid,
+		Failed:     len(invalidRecs),
+		Took:       elapsed,
+		Speed:      float64(numValid+len(invalidRecs)) / elapsed.Seconds(),
+		Areas:      summary.Areas,
+		TopInvalid: summary.TopInvalidValues,
+	}
+
+	// take the first few invalid rows (by row id) seen for each reason
+	samples := make(map[string][]ReportSample)
+	for _, rec := range invalidRecs {
+		if len(samples[rec.reason]) < HTML_REPORT_SAMPLES_PER_REASON {
+			samples[rec.reason] = append(samples[rec.reason], ReportSample{RowId: rec.rowId, Postcode: rec.postcode})
+		}
+	}
+
+	for _, c := range summary.Reasons {
+		reason := ReportReason{Reason: c.Key, Count: c.Invalid, Samples: samples[c.Key]}
+		if report.Failed > 0 {
+			reason.Percent = 100 * float64(c.Invalid) / float64(report.Failed)
+		}
+		report.Reasons = append(report.Reasons, reason)
+	}
+
+	// list the files in a fixed order by their role
+	roles := make([]string, 0, len(files))
+	for role := range files {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		info := ReportFileInfo{Role: role, Path: files[role]}
+		if stat, err := os.Stat(files[role]); err == nil {
+			info.Size = stat.Size()
+			info.Modified = stat.ModTime()
+		} else {
+			info.Missing = true
+		}
+		report.Files = append(report.Files, info)
+	}
+
+	return report
+}
+
+// "writeHtmlReport" renders the report to a single html file at "path", the styles are written into the
+// file so it can be opened without any other files
+func writeHtmlReport(path string, report *HtmlReport) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := htmlReportTemplate.Execute(file, report); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// the template used to render the html report
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Postcode validation report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1, h2 { font-weight: normal; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+th { background: #eee; }
+td.num { text-align: right; }
+.failed { color: #a00; }
+.succeeded { color: #070; }
+</style>
+</head>
+<body>
+<h1>Postcode validation report</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05"}} using the <code>{{.Profile}}</code> rule profile.</p>
+
+<h2>Completion report</h2>
+<table>
+<tr><th>Total records</th><td class="num">{{.Total}}</td></tr>
+<tr><th>Succeeded</th><td class="num succeeded">{{.Succeeded}}</td></tr>
+<tr><th>Failed</th><td class="num failed">{{.Failed}}</td></tr>
+<tr><th>Took</th><td class="num">{{.Took}}</td></tr>
+<tr><th>Speed</th><td class="num">{{printf "%.2f" .Speed}} records per second</td></tr>
+</table>
+
+<h2>Failure reasons</h2>
+{{if .Reasons}}<table>
+<tr><th>Reason</th><th>Count</th><th>% of failed</th><th>Sample rows (row id: postcode)</th></tr>
+{{range .Reasons}}<tr><td>{{.Reason}}</td><td class="num">{{.Count}}</td><td class="num">{{printf "%.2f" .Percent}}</td><td>{{range $i, $rec := .Samples}}{{if $i}}<br>{{end}}{{$rec.RowId}}: <code>{{$rec.Postcode}}</code>{{end}}</td></tr>
+{{end}}</table>{{else}}<p>No records failed validation.</p>{{end}}
+
+<h2>Most frequent invalid values</h2>
+{{if .TopInvalid}}<table>
+<tr><th>Value</th><th>Count</th></tr>
+{{range .TopInvalid}}<tr><td><code>{{.Key}}</code></td><td class="num">{{.Invalid}}</td></tr>
+{{end}}</table>{{else}}<p>No records failed validation.</p>{{end}}
+
+<h2>Records by postcode area</h2>
+<table>
+<tr><th>Area</th><th>Succeeded</th><th>Failed</th></tr>
+{{range .Areas}}<tr><td>{{if .Key}}{{.Key}}{{else}}<em>(none)</em>{{end}}</td><td class="num">{{.Valid}}</td><td class="num">{{.Invalid}}</td></tr>
+{{end}}</table>
+
+<h2>Files</h2>
+<table>
+<tr><th>Role</th><th>Path</th><th>Size (bytes)</th><th>Modified</th></tr>
+{{range .Files}}<tr><td>{{.Role}}</td><td><code>{{.Path}}</code></td>{{if .Missing}}<td colspan="2"><em>not found</em></td>{{else}}<td class="num">{{.Size}}</td><td>{{.Modified.Format "2006-01-02 15:04:05"}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
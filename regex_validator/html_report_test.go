@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// expected: the report lists each failure reason with its samples & escapes the invalid values
+// render a report for a small set of records
+func Test_writeHtmlReport__Render(t *testing.T) {
+
+	stats := NewValidationStatistics()
+	var invalid ImportRecordGroup
+	numValid := 0
+
+	for i, postcode := range []string{"M1 1AE", "LS44PL", "<b>junk</b>", "FY10 4PL"} {
+		rec := newValidatedTestRecord(postcode)
+		rec.rowId = uint64(i + 1)
+		stats.Add(rec)
+		if rec.isValid {
+			numValid++
+		} else {
+			invalid = append(invalid, rec)
+		}
+	}
+
+	report := newHtmlReport(time.Now(), PROFILE_BRIEF_2017, invalid, numValid, stats.Summary(10), map[string]string{"input": "does_not_exist.csv"})
+
+	var buf bytes.Buffer
+	if err := htmlReportTemplate.Execute(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+
+	expected := []string{
+		`<td class="num">4</td>`,
+		REASON_NO_SPACE, REASON_JUNK, REASON_SINGLE_DIGIT_AREA,
+		"2: <code>LS44PL</code>",
+		"&lt;b&gt;junk&lt;/b&gt;",
+		"<em>not found</em>",
+	}
+	for _, str := range expected {
+		if !strings.Contains(html, str) {
+			error := fmt.Sprintf("Expected the report to contain: %s", str)
+			t.Error(error)
+		}
+	}
+
+	if strings.Contains(html, "<b>junk</b>") {
+		t.Error("Expected invalid values to be escaped in the report")
+	}
+}
@@ -1,33 +1,57 @@
 package main
 
 import (
-	"strconv"
+	"errors"
 )
 
 // type to represet a record from an imported .csv file , rowId & postcodes or the record is stored in
-// their native types rather than both being stored as strings
+// their native types rather than both being stored as strings. The components of the postcode & the
+// directory status are only filled in once the record has been validated and found to be valid, the reason
+// is filled in for invalid records & for valid records whose postcode could not be split into components
+// (REASON_NO_COMPONENTS). The suggestion & its confidence are only filled in for invalid records when
+// suggestions are asked for.
+// "source" is the input file the record was read from & "lineNumber" its line in that file
 type ImportRecord struct {
-	rowId    uint32
-	postcode string
-	isValid  bool
+	rowId           uint64
+	source          *InputFile
+	lineNumber      uint64
+	postcode        string
+	isValid         bool
+	class           PostcodeClass
+	components      PostcodeComponents
+	directoryStatus DirectoryStatus
+	terminated      string
+	reason          string
+	suggestion      string
+	confidence      float64
 }
 
 // takes a record read by a cvs reader (which creates a string slice of each record) and creates a properly
-// typed ImportRecord from this slice
+// typed ImportRecord from this slice, any row id that is not negative is accepted
 func NewImportRecord(record []string) *ImportRecord {
+	rec, err := NewImportRecordInRange(record, ROW_ID_RANGE_DEFAULT)
+	check(err)
+	return rec
+}
+
+// same as NewImportRecord but returns an error if the record does not have 2 fields or its row id is not
+// within the range "rng"
+func NewImportRecordInRange(record []string, rng RowIdRange) (*ImportRecord, error) {
 	if len(record) > FIELDS_PER_RECORD || len(record) < FIELDS_PER_RECORD {
-		panic("invalid record received")
+		return nil, errors.New("invalid record received")
 	}
 
 	const ROW_ID_IDX = 0
 	const POSTCODE_IDX = 1
 
-	rowIdInt, err := strconv.ParseInt(record[ROW_ID_IDX], 10, 32)
-	check(err)
+	rowId, err := parseRowId(record[ROW_ID_IDX], rng)
+	if err != nil {
+		return nil, err
+	}
 
-	return &ImportRecord{rowId: uint32(rowIdInt),
+	return &ImportRecord{rowId: rowId,
 		postcode: record[POSTCODE_IDX],
-		isValid:  false}
+		isValid:  false}, nil
 }
 
 type ImportRecordGroup []*ImportRecord
@@ -48,9 +72,18 @@ func (coll ImportRecordGroup) Swap(i, j int) {
 	coll[i], coll[j] = coll[j], coll[i]
 }
 
-// returns a boolean indicating if the item at "i" in the ImportRecordGroup is less than the item at "j"
+// returns a boolean indicating if the item at "i" in the ImportRecordGroup is less than the item at "j", items
+// with the same rowId are ordered by the file & line they were read from so the order is the same every run
 func (coll ImportRecordGroup) Less(i, j int) bool {
-	return coll[i].rowId < coll[j].rowId
+	if coll[i].rowId != coll[j].rowId {
+		return coll[i].rowId < coll[j].rowId
+	}
+	return coll[i].location().before(coll[j].location())
+}
+
+// returns the input file & line the record was read from
+func (rec *ImportRecord) location() RecordLocation {
+	return RecordLocation{source: rec.source, lineNumber: rec.lineNumber}
 }
 
 // ------------------------------------------------------------------------------------------------------
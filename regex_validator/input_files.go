@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the extra column written to both output files when several input files are merged
+const SOURCE_FILE_COLUMN = "source_file"
+
+// what is done with a line of an input file that is empty or only holds space: it is skipped (the default) or
+// is a malformed row
+const (
+	BLANK_LINES_SKIP  = "skip"
+	BLANK_LINES_ERROR = "error"
+)
+
+// what is done with a malformed row (one that can not be split into a row id & postcode, or whose row id is not a
+// number in -row-id-range): it stops the run (the default) or is skipped & counted
+const (
+	MALFORMED_ROWS_ERROR = "error"
+	MALFORMED_ROWS_SKIP  = "skip"
+)
+
+// type that stores an input file, its position in the list of input files & the column names read from its header.
+// A run that writes checkpoints reads the file a segment at a time, "file" & its UTF-8 "text" are left open
+// between segments with "textOffset" the offset of the text reached, so each segment carries on from the last
+// rather than opening the file (& decoding an encoding other than UTF-8) again from its start
+type InputFile struct {
+	index       int
+	path        string
+	columnNames []string
+	file        *os.File
+	text        *bufio.Reader
+	textOffset  int64
+}
+
+// "closeText" closes the input file if it was left open, it is only read so an error closing it is ignored
+func (input *InputFile) closeText() {
+	if input.file != nil {
+		input.file.Close()
+	}
+	input.file, input.text, input.textOffset = nil, nil, 0
+}
+
+// "closeInputFiles" closes every input file left open
+func closeInputFiles(inputs []*InputFile) {
+	for _, input := range inputs {
+		input.closeText()
+	}
+}
+
+// type that stores where a record was read from, the line number of the file at index 0 when there is one input
+type RecordLocation struct {
+	source     *InputFile
+	lineNumber uint64
+}
+
+// "sourceIndex" returns the position of the location's input file, 0 if it is not known
+func (loc RecordLocation) sourceIndex() int {
+	if loc.source == nil {
+		return 0
+	}
+	return loc.source.index
+}
+
+// "before" reports whether "loc" comes before "other", input files are read in order so this is the order
+// the records were read in
+func (loc RecordLocation) before(other RecordLocation) bool {
+	if loc.sourceIndex() != other.sourceIndex() {
+		return loc.sourceIndex() < other.sourceIndex()
+	}
+	return loc.lineNumber < other.lineNumber
+}
+
+// "format" returns the line number, prefixed with the path of the input file & a colon when "withSource" is set
+func (loc RecordLocation) format(withSource bool) string {
+	if withSource && loc.source != nil {
+		return loc.source.path + ":" + strconv.FormatUint(loc.lineNumber, 10)
+	}
+	return strconv.FormatUint(loc.lineNumber, 10)
+}
+
+// type of flag that can be given more than once, each value is kept in order
+type stringListFlag []string
+
+func (list *stringListFlag) String() string { return strings.Join(*list, ",") }
+
+func (list *stringListFlag) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// "expandInputPaths" turns each of "patterns" into the files with the extension "extension" (.csv unless another
+// input format is used) it names: a file is used as it is, a directory gives each such file directly inside it
+// (in name order) & anything else is treated as a glob pattern. Files named more than once are only used once,
+// an error is returned if a pattern names no such file
+func expandInputPaths(patterns []string, extension string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+
+	add := func(path string) {
+		if clean := filepath.Clean(path); !seen[clean] {
+			seen[clean] = true
+			paths = append(paths, clean)
+		}
+	}
+
+	for _, pattern := range patterns {
+		info, err := os.Stat(pattern)
+
+		switch {
+		case err == nil && !info.IsDir():
+			if filepath.Ext(pattern) != extension {
+				return nil, fmt.Errorf("File must have the extension %s: \"%s\"", extension, pattern)
+			}
+			add(pattern)
+
+		case err == nil && info.IsDir():
+			matches, err := filepath.Glob(filepath.Join(globEscape(pattern), "*"+extension))
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("The directory provided has no %s files: \"%s\"", extension, pattern)
+			}
+			sort.Strings(matches)
+			for _, match := range matches {
+				add(match)
+			}
+
+		default:
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("The pattern provided is not valid: \"%s\": %s", pattern, err)
+			}
+			sort.Strings(matches)
+			found := false
+			for _, match := range matches {
+				if info, err := os.Stat(match); err == nil && !info.IsDir() && filepath.Ext(match) == extension {
+					add(match)
+					found = true
+				}
+			}
+			if !found {
+				return nil, &InputError{path: pattern, err: fmt.Errorf("no %s file exists at this path or matches this pattern", extension)}
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// "globEscape" escapes the characters of "path" that filepath.Glob would treat as a pattern
+func globEscape(path string) string {
+	var escaped strings.Builder
+	for _, c := range path {
+		if strings.ContainsRune(`*?[\`, c) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(c)
+	}
+	return escaped.String()
+}
+
+// "newInputFiles" creates an InputFile for each path, in order
+func newInputFiles(paths []string) []*InputFile {
+	inputs := make([]*InputFile, len(paths))
+	for i, path := range paths {
+		inputs[i] = &InputFile{index: i, path: path}
+	}
+	return inputs
+}
+
+// "perInputOutputDirs" returns the directory inside "outputDir" each input file's outputs are written to when
+// -per-input is given, named after the file without its extension. An error is returned if two input files
+// would share a directory
+func perInputOutputDirs(paths []string, outputDir string) ([]string, error) {
+	dirs := make([]string, len(paths))
+	used := make(map[string]string)
+
+	for i, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if other, ok := used[name]; ok {
+			return nil, fmt.Errorf("The input files \"%s\" & \"%s\" would both write their output to \"%s\", rename one of them", other, path, name)
+		}
+		used[name] = path
+		dirs[i] = filepath.Join(outputDir, name)
+	}
+	return dirs, nil
+}
+
+// the error a blank line is reported with, as a ParseError, when -blank-lines is "error"
+var errBlankLine = errors.New("the line is blank")
+
+// "checkBlankLines" returns an error if "name" is not a value -blank-lines can be given
+func checkBlankLines(name string) error {
+	if name != BLANK_LINES_SKIP && name != BLANK_LINES_ERROR {
+		return fmt.Errorf("unknown -blank-lines \"%s\", must be one of: %s, %s", name, BLANK_LINES_SKIP, BLANK_LINES_ERROR)
+	}
+	return nil
+}
+
+// "checkMalformedRows" returns an error if "name" is not a value -malformed-rows can be given
+func checkMalformedRows(name string) error {
+	if name != MALFORMED_ROWS_ERROR && name != MALFORMED_ROWS_SKIP {
+		return fmt.Errorf("unknown -malformed-rows \"%s\", must be one of: %s, %s", name, MALFORMED_ROWS_ERROR, MALFORMED_ROWS_SKIP)
+	}
+	return nil
+}
+
+// "readInputLine" reads the next line of "reader" along with its line ending, which may be "\n", "\r\n" or a lone
+// "\r" (so a line's length is the number of bytes it takes in the text). The last line need not have a line ending,
+// io.EOF is returned once every line has been read. Nothing past the line is read from "reader"
+func readInputLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		if reader.Buffered() == 0 {
+			if _, err := reader.Peek(1); err == io.EOF && len(line) > 0 {
+				return string(line), nil
+			} else if err != nil {
+				return "", err
+			}
+		}
+		buffered, _ := reader.Peek(reader.Buffered())
+
+		// only the text before the first "\n" is searched for a "\r", so each byte is looked at once
+		end := bytes.IndexByte(buffered, '\n')
+		if end < 0 {
+			end = len(buffered)
+		}
+		if cr := bytes.IndexByte(buffered[:end], '\r'); cr >= 0 && (cr+1 == len(buffered) || buffered[cr+1] != '\n') {
+			end = cr
+		}
+		if end == len(buffered) {
+			line = append(line, buffered...)
+			reader.Discard(len(buffered))
+			continue
+		}
+
+		// a "\r" that was the last byte buffered may be followed by a "\n"
+		if buffered[end] == '\r' && end+1 == len(buffered) {
+			line = append(line, buffered[:end+1]...)
+			reader.Discard(end + 1)
+			if next, err := reader.Peek(1); err == nil && next[0] == '\n' {
+				line = append(line, '\n')
+				reader.Discard(1)
+			}
+			return string(line), nil
+		}
+
+		// most lines are in the buffer whole, so are copied from it once
+		var text string
+		if line == nil {
+			text = string(buffered[:end+1])
+		} else {
+			text = string(append(line, buffered[:end+1]...))
+		}
+		reader.Discard(end + 1)
+		return text, nil
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// "createTempFiles" creates an empty file for each of "names" (which may include sub directories) inside a new
+// temporary directory and returns the directory, which should be removed by the caller
+func createTempFiles(t *testing.T, names ...string) string {
+	dir, err := ioutil.TempDir("", "inputs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		check(os.MkdirAll(filepath.Dir(path), 0755))
+		check(ioutil.WriteFile(path, nil, 0644))
+	}
+	return dir
+}
+
+// expected: files are used as they are, directories give the .csv files inside them & globs give the .csv files
+// they match, each in name order and without repeats
+func Test_expandInputPaths__FilesDirectoriesAndGlobs(t *testing.T) {
+	dir := createTempFiles(t, "a.csv", "shards/c.csv", "shards/b.csv", "shards/notes.txt", "shards/deeper/d.csv", "x1.csv", "x2.csv")
+	defer os.RemoveAll(dir)
+	at := func(name string) string { return filepath.Join(dir, name) }
+
+	testCases := []struct {
+		patterns []string
+		expected []string
+	}{
+		{[]string{at("a.csv")}, []string{at("a.csv")}},
+		{[]string{at("shards")}, []string{at("shards/b.csv"), at("shards/c.csv")}},
+		{[]string{at("x*.csv")}, []string{at("x1.csv"), at("x2.csv")}},
+		{[]string{at("*")}, []string{at("a.csv"), at("x1.csv"), at("x2.csv")}},
+		{[]string{at("a.csv"), at("shards"), at("shards/b.csv"), at("./a.csv")}, []string{at("a.csv"), at("shards/b.csv"), at("shards/c.csv")}},
+	}
+
+	for _, element := range testCases {
+		result, err := expandInputPaths(element.patterns, ".csv")
+
+		if err != nil || !reflect.DeepEqual(result, element.expected) {
+			error := fmt.Sprintf("Given patterns: %v, Expected: %v   got: %v (error: %v)", element.patterns, element.expected, result, err)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: an error when a pattern names no .csv file, an InputError when nothing exists at the path
+func Test_expandInputPaths__NoFiles(t *testing.T) {
+	dir := createTempFiles(t, "notes.txt", "empty/notes.txt")
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"notes.txt", "empty", "missing.csv", "*.csv"} {
+		pattern := filepath.Join(dir, name)
+		result, err := expandInputPaths([]string{pattern}, ".csv")
+
+		if err == nil {
+			error := fmt.Sprintf("Given pattern: %s, Expected: an error   got: %v", pattern, result)
+			t.Error(error)
+		}
+		if _, ok := err.(*InputError); ok != (name == "missing.csv" || name == "*.csv") {
+			error := fmt.Sprintf("Given pattern: %s, Expected InputError: %t   got: %v", pattern, !ok, err)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: each input is given a directory named after it, two inputs with the same name are an error
+func Test_perInputOutputDirs(t *testing.T) {
+	result, err := perInputOutputDirs([]string{"north/leeds.csv", "south/brighton.csv"}, "out")
+	expected := []string{filepath.Join("out", "leeds"), filepath.Join("out", "brighton")}
+
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		error := fmt.Sprintf("Expected: %v   got: %v (error: %v)", expected, result, err)
+		t.Error(error)
+	}
+
+	if _, err := perInputOutputDirs([]string{"north/leeds.csv", "south/leeds.csv"}, "out"); err == nil {
+		t.Error("Given two inputs named leeds.csv, Expected: an error   got: nil")
+	}
+}
+
+// expected: locations are ordered by input file then line & only show the path when asked to
+func Test_RecordLocation(t *testing.T) {
+	inputs := newInputFiles([]string{"a.csv", "b.csv"})
+	a9 := RecordLocation{source: inputs[0], lineNumber: 9}
+	b2 := RecordLocation{source: inputs[1], lineNumber: 2}
+
+	if !a9.before(b2) || b2.before(a9) {
+		t.Error("Given a.csv line 9 & b.csv line 2, Expected: a.csv line 9 first   got: b.csv line 2 first")
+	}
+
+	if result := b2.format(true); result != "b.csv:2" {
+		error := fmt.Sprintf("Given b.csv line 2 with its source, Expected: b.csv:2   got: %s", result)
+		t.Error(error)
+	}
+	if result := b2.format(false); result != "2" {
+		error := fmt.Sprintf("Given b.csv line 2 without its source, Expected: 2   got: %s", result)
+		t.Error(error)
+	}
+}
+
+// expected: each line with its line ending, whichever of "\n", "\r\n" or "\r" it is, and the last line without
+// one. The smallest buffer makes lines & "\r\n" pairs cross the end of what is buffered
+func Test_readInputLine(t *testing.T) {
+	tests := map[string][]string{
+		"":                          nil,
+		"1,A\n2,B\n":                {"1,A\n", "2,B\n"},
+		"1,A\n2,B":                  {"1,A\n", "2,B"},
+		"1,A\r\n2,B\r\n":            {"1,A\r\n", "2,B\r\n"},
+		"1,A\r2,B\r":                {"1,A\r", "2,B\r"},
+		"1,A\r\n\r\n\n\r2,B":        {"1,A\r\n", "\r\n", "\n", "\r", "2,B"},
+		"row_id,postcode\r\n1,EC1A": {"row_id,postcode\r\n", "1,EC1A"},
+		"row_id,postcode\r1,EC1A 1BB is a postcode that is longer than the buffer\r\n": {
+			"row_id,postcode\r", "1,EC1A 1BB is a postcode that is longer than the buffer\r\n"},
+	}
+
+	for text, expected := range tests {
+		reader := bufio.NewReaderSize(strings.NewReader(text), 16)
+		var lines []string
+		for {
+			line, err := readInputLine(reader)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, line)
+		}
+
+		if !reflect.DeepEqual(lines, expected) {
+			error := fmt.Sprintf("Given: %q, Expected: %q   got: %q", text, expected, lines)
+			t.Error(error)
+		}
+	}
+}
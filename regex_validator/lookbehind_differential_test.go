@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// A test-only reference implementation of the brief's original regex, negative look-behinds included, and a
+// differential test that compares it to createMainRegexValidatorGroup on every structurally possible postcode.
+// The reference is a small matcher for fixed width patterns, each alternative of the original regex is written
+// as a list of tokens exactly as it appears in the brief. The original regex is applied to the whole string
+
+// type that stores a single token of a pattern, either a character class that consumes one character or a
+// negative look-behind that asserts none of its alternatives match the characters just before the cursor
+type refToken struct {
+	class       string
+	notPreceded [][]string
+}
+
+// "cls" & "notAfter" build the two kinds of refToken
+func cls(class string) refToken { return refToken{class: class} }
+
+func notAfter(alternatives ...[]string) refToken { return refToken{notPreceded: alternatives} }
+
+// "lit" returns the classes that match the literal string "str"
+func lit(str string) []string {
+	classes := make([]string, len(str))
+	for i := range str {
+		classes[i] = str[i : i+1]
+	}
+	return classes
+}
+
+// the areas that only have single digit districts, (BR|FY|HA|HD|HG|HR|HS|HX|JE|LD|SM|SR|WC|WN|ZE)[0-9] in the brief
+func singleDigitAreaLookbehind() [][]string {
+	var alternatives [][]string
+	for _, area := range []string{"BR", "FY", "HA", "HD", "HG", "HR", "HS", "HX", "JE", "LD", "SM", "SR", "WC", "WN", "ZE"} {
+		alternatives = append(alternatives, append(lit(area), classDigit))
+	}
+	return alternatives
+}
+
+// the alternatives of the original regex's outward code, written token by token as in the brief
+var originalOutwardAlternatives = [][]refToken{
+	// A9 or A99 prefix: ([A-PR-UWYZ][0-9][0-9]?)
+	{cls(classFirst), cls(classDigit)},
+	{cls(classFirst), cls(classDigit), cls(classDigit)},
+	// AA99 prefix with some excluded areas: ([A-PR-UWYZ][A-HK-Y][0-9](?<!(BR|...|ZE)[0-9])[0-9])
+	{cls(classFirst), cls(classSecond), cls(classDigit), notAfter(singleDigitAreaLookbehind()...), cls(classDigit)},
+	// AA9 prefix with some excluded areas: ([A-PR-UWYZ][A-HK-Y](?<!AB|LL|SO)[0-9])
+	{cls(classFirst), cls(classSecond), notAfter(lit("AB"), lit("LL"), lit("SO")), cls(classDigit)},
+	// WC1A prefix: (WC[0-9][A-Z])
+	{cls("W"), cls("C"), cls(classDigit), cls(classUpper)},
+	// A9A prefix: ([A-PR-UWYZ][0-9][A-HJKPSTUW])
+	{cls(classFirst), cls(classDigit), cls(classThird)},
+	// AA9A prefix: ([A-PR-UWYZ][A-HK-Y][0-9][ABEHMNPRVWXY])
+	{cls(classFirst), cls(classSecond), cls(classDigit), cls(classFourth)},
+}
+
+// "refMatchesExactly" reports whether "tokens" match the whole of "s"
+func refMatchesExactly(s string, tokens []refToken) bool {
+	pos := 0
+	for _, tok := range tokens {
+		if tok.notPreceded != nil {
+			for _, alt := range tok.notPreceded {
+				if matchesClasses(s, pos-len(alt), alt...) {
+					return false
+				}
+			}
+			continue
+		}
+		if pos >= len(s) || !inClass(s[pos], tok.class) {
+			return false
+		}
+		pos++
+	}
+	return pos == len(s)
+}
+
+// "referenceOriginalRegexIsValid" applies the original regex, look-behinds included, to the whole of "s"
+func referenceOriginalRegexIsValid(s string) bool {
+	// (GIR\s0AA)
+	if len(s) == 7 && s[:3] == "GIR" && isRegexSpace(s[3]) && s[4:] == "0AA" {
+		return true
+	}
+
+	// 9AA suffix: \s[0-9][ABD-HJLNP-UW-Z]{2}
+	n := len(s)
+	if n < 6 || !isRegexSpace(s[n-4]) || !matchesClasses(s, n-3, classDigit, classUnit, classUnit) {
+		return false
+	}
+
+	outward := s[:n-4]
+	for _, alt := range originalOutwardAlternatives {
+		if refMatchesExactly(outward, alt) {
+			return true
+		}
+	}
+	return false
+}
+
+// "forEachOutwardShape" calls "fn" with every string of every outward code shape (A9, A99, AA9, AA99, A9A, AA9A,
+// A being any upper case letter & 9 any digit)
+func forEachOutwardShape(fn func(outward string)) {
+	const letters = classUpper
+	const digits = classDigit
+
+	var build func(prefix []byte, shape string)
+	build = func(prefix []byte, shape string) {
+		if len(shape) == 0 {
+			fn(string(prefix))
+			return
+		}
+		chars := letters
+		if shape[0] == '9' {
+			chars = digits
+		}
+		for i := 0; i < len(chars); i++ {
+			build(append(prefix, chars[i]), shape[1:])
+		}
+	}
+
+	for _, shape := range []string{"A9", "A99", "AA9", "AA99", "A9A", "AA9A"} {
+		build(make([]byte, 0, 4), shape)
+	}
+}
+
+// type that stores the divergences found by a differential test, only the first few are kept to report
+type divergences struct {
+	count    int
+	examples []string
+}
+
+// "check" compares the emulation & reference on "postcode", recording it if they disagree
+func (d *divergences) check(validator *RegexValidatorGroup, postcode string) {
+	emulated := validator.GroupIsStringValid(postcode)
+	original := referenceOriginalRegexIsValid(postcode)
+	if emulated != original {
+		d.count++
+		if len(d.examples) < 20 {
+			d.examples = append(d.examples, fmt.Sprintf("%q (emulation: %t, original: %t)", postcode, emulated, original))
+		}
+	}
+}
+
+// "report" fails the test if any divergence was found
+func (d *divergences) report(t *testing.T, checked int) {
+	if d.count > 0 {
+		t.Errorf("%d of %d postcodes diverged from the original regex, for example:", d.count, checked)
+		for _, example := range d.examples {
+			t.Errorf("    %s", example)
+		}
+	}
+}
+
+// expected: the reference agrees with the Part 1 table, so it can be trusted as the original regex
+func Test_referenceOriginalRegexIsValid__Part1Table(t *testing.T) {
+	for _, element := range brief2017ConformanceTable {
+		if result := referenceOriginalRegexIsValid(element.postcode); result != element.expected {
+			error := fmt.Sprintf("Given postcode: %s, Expected: %t   got: %t", element.postcode, element.expected, result)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: no divergence
+// every outward code of every shape, combined with one valid & several invalid inward codes
+func Test_Differential__EveryOutwardCode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the exhaustive differential test in short mode")
+	}
+
+	validator := createMainRegexValidatorGroup()
+	var d divergences
+	checked := 0
+
+	// a valid inward code, GIR's inward code, & one with each kind of error, digits & valid unit letters
+	// are interchangeable in the inward code so these cover every case
+	inwardCodes := []string{" 9AA", " 0AA", " 9CA", " 9AC", " AAA"}
+
+	forEachOutwardShape(func(outward string) {
+		for _, inward := range inwardCodes {
+			d.check(validator, outward+inward)
+			checked++
+		}
+	})
+
+	d.report(t, checked)
+}
+
+// expected: no divergence
+// every inward code (digit or letter in each position) & separator, combined with an outward code of each shape
+func Test_Differential__EveryInwardCode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the exhaustive differential test in short mode")
+	}
+
+	validator := createMainRegexValidatorGroup()
+	var d divergences
+	checked := 0
+
+	outwardCodes := []string{"GIR", "M1", "B33", "CR2", "SO1", "DN55", "FY10", "W1A", "EC1A", "WC1A", "AA9C"}
+	chars := classDigit + classUpper
+
+	for _, outward := range outwardCodes {
+		for _, sep := range []string{" ", "\t", ""} {
+			for i := 0; i < len(chars); i++ {
+				for j := 0; j < len(chars); j++ {
+					for k := 0; k < len(chars); k++ {
+						d.check(validator, outward+sep+string([]byte{chars[i], chars[j], chars[k]}))
+						checked++
+					}
+				}
+			}
+		}
+	}
+
+	d.report(t, checked)
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -19,51 +21,252 @@ const (
 	BATCH_SIZE_DEFAULT int = 10000
 	FIELDS_PER_RECORD  int = 2
 	CHAN_DEFAULT_SIZE  int = 2000
+
+	SUCCEEDED_FILE_NAME = "succeeded_validation.csv"
+	FAILED_FILE_NAME    = "failed_validation.csv"
 )
 
 func main() {
 
+	// the generate subcommand writes a synthetic import file rather than validating one
+	if len(os.Args) > 1 && os.Args[1] == GENERATE_COMMAND {
+		runGenerateCommand(os.Args[2:])
+		return
+	}
+
+	// the diff subcommand compares the outputs of two runs rather than validating a file
+	if len(os.Args) > 1 && os.Args[1] == DIFF_COMMAND {
+		runDiffCommand(os.Args[2:])
+		return
+	}
+
 	// start timer
 	startTime := time.Now()
 
-	// get the file name sent in via the command line flag ------------------------------------------------
-	path, showReport := getCommandLineArgs()
+	// get the file name & options sent in via the command line flags ------------------------------------
+	opts := getCommandLineArgs()
 
-	// use the file name to find the file and open it -----------------------------------------------------
-	csvFile, err := os.Open(path)
-	check(err)
+	// profile the run, if asked for
+	stopProfiling, err := startProfiling(opts.profiling)
+	if err != nil {
+		errorExit(err.Error(), exitCodeForError(err))
+	}
 
-	// defer the closing of the file until the end of main()
-	defer func() {
-		e := csvFile.Close()
-		check(e)
-	}()
+	// validate every input file together, or each one on its own if asked for
+	run := runImport
+	if opts.perInput {
+		run = runImportPerInput
+	}
+	result, err := run(startTime, opts)
 
-	// batch read the file line by line (Read 10,000 at a time, and fill up 10k structs) ------------------
+	if err := stopProfiling(); err != nil {
+		errorExit(err.Error(), exitCodeForError(err))
+	}
 
-	// create a reader to read csv files
-	bufReader := bufio.NewReader(csvFile)
+	// exit with a code that tells the caller how the run went
+	if err != nil {
+		errorExit(err.Error(), exitCodeForError(err))
+	}
+	if result.numInvalid > 0 {
+		os.Exit(EXIT_SOME_INVALID)
+	}
+}
 
-	// first record in the csv file will be titles so read it and keep the result for output titles
-	tempLine, err := bufReader.ReadString('\n')
-	check(err)
-	res := strings.Split(tempLine, ",")
-	columnNames := []string{strings.TrimSpace(res[0]), strings.TrimSpace(res[1])}
+// type that stores the outcome of a run
+type ImportResult struct {
+	numValid     int
+	numInvalid   int
+	numMalformed int // the malformed rows skipped, with -malformed-rows skip
+}
 
-	// create the regex validator group we will use to validate the postcodes
-	validator := createMainRegexValidatorGroup()
+// "runImportPerInput" runs "runImport" on each input file in turn, writing the output of each to its own
+// directory inside the output directory (see perInputOutputDirs). The results are added together, the
+// completion report & -max-invalid-ratio apply to the totals. The first error stops the run
+func runImportPerInput(startTime time.Time, opts *ProgramOptions) (ImportResult, error) {
+	dirs, err := perInputOutputDirs(opts.paths, opts.outputDir)
+	if err != nil {
+		return ImportResult{}, err
+	}
 
-	// we need a wait group to sync our go routines that are running in parallel
-	var readRecordWG sync.WaitGroup
+	var total ImportResult
+	results := make([]ImportResult, len(opts.paths))
 
-	// run a number of go routines in a parallel pipelines pattern [readFromInputFile_go -> createInputRecords_go -> validateInputRecords_go]
-	// each function does its job concurrently until there is no more work to do, the WaitGroup readRecordWG sycncronises them with main()
-	readLines_chan := readFromInputFile_go(&readRecordWG, bufReader)
-	createdInputRecords_chan := createInputRecords_go(&readRecordWG, readLines_chan)
-	validImportRecs, invalidImportRecs := validateInputRecords(createdInputRecords_chan, validator)
+	for i, path := range opts.paths {
+		if err := os.MkdirAll(dirs[i], 0755); err != nil {
+			return total, &OutputError{path: dirs[i], err: err}
+		}
 
-	// make the main function wait until all functions in the "readRecordWG" have completed - we need all records to be validated before sorting
-	readRecordWG.Wait()
+		// each input is run with the same options, only the report & the threshold are left to the end
+		inputOpts := *opts
+		inputOpts.paths = []string{path}
+		inputOpts.outputDir = dirs[i]
+		inputOpts.showReport = false
+		inputOpts.maxInvalidRatio = 1
+		if len(opts.htmlReportPath) > 0 {
+			inputOpts.htmlReportPath = filepath.Join(dirs[i], filepath.Base(opts.htmlReportPath))
+		}
+		if len(opts.sqlitePath) > 0 {
+			inputOpts.sqlitePath = filepath.Join(dirs[i], filepath.Base(opts.sqlitePath))
+		}
+
+		results[i], err = runImport(startTime, &inputOpts)
+		total.numValid += results[i].numValid
+		total.numInvalid += results[i].numInvalid
+		total.numMalformed += results[i].numMalformed
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if opts.showReport {
+		for i, path := range opts.paths {
+			fmt.Printf("%s: %d succeeded, %d failed, written to %s\n", path, results[i].numValid, results[i].numInvalid, dirs[i])
+		}
+		printCompletionReport(startTime, total.numValid, total.numInvalid, total.numMalformed)
+	}
+
+	if err := checkInvalidRatio(total, opts.maxInvalidRatio); err != nil {
+		return total, err
+	}
+	return total, nil
+}
+
+// "checkInvalidRatio" returns a ValidationError if the share of invalid records in "result" is above "threshold"
+func checkInvalidRatio(result ImportResult, threshold float64) error {
+	total := result.numValid + result.numInvalid
+	if total > 0 && float64(result.numInvalid)/float64(total) > threshold {
+		return &ValidationError{numInvalid: result.numInvalid, total: total, threshold: threshold}
+	}
+	return nil
+}
+
+// "runImport" validates the input files described by "opts" through one pipeline, as if they were one file, and
+// writes every output file that was asked for into the output directory with the records of every input
+// merged & sorted by row id. "startTime" is used for the completion report. The error returned is one of
+// InputError, ParseError, ValidationError or OutputError (or a plain error if an option is not valid)
+func runImport(startTime time.Time, opts *ProgramOptions) (ImportResult, error) {
+
+	// each input file is opened & read in turn by the first stage of the pipeline, its header is kept in its InputFile.
+	// A file left open between checkpoints is closed if the run stops part way through it
+	inputs := newInputFiles(opts.paths)
+	defer closeInputFiles(inputs)
+
+	// the format the input files are read in & the sinks the valid & invalid records are written to
+	inputFormat, err := newInputFormat(opts.inputFormat, opts.jsonFields)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	if err := checkEncoding(opts.encoding); err != nil {
+		return ImportResult{}, err
+	}
+	inputFormat.encoding = opts.encoding
+	if err := checkBlankLines(opts.blankLines); err != nil {
+		return ImportResult{}, err
+	}
+	inputFormat.blankLinesMalformed = opts.blankLines == BLANK_LINES_ERROR
+	if err := checkMalformedRows(opts.malformedRows); err != nil {
+		return ImportResult{}, err
+	}
+	inputFormat.skipMalformed = opts.malformedRows == MALFORMED_ROWS_SKIP
+	validSink, invalidSink, err := newOutputSinks(opts)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	// create the regex validator group we will use to validate the postcodes, using the selected rule profile
+	validator, err := createRegexValidatorGroupForProfile(opts.profile)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	// load the postcode directory used to check valid postcodes exist, if one was given
+	var directory *PostcodeDirectory
+	if len(opts.directoryPath) > 0 {
+		directory, err = loadPostcodeDirectory(opts.directoryPath)
+		if err != nil {
+			return ImportResult{}, &InputError{path: opts.directoryPath, err: err}
+		}
+	}
+
+	// create the suggestion engine used to correct invalid postcodes, if suggestions were asked for
+	var suggester *SuggestionEngine
+	if opts.showSuggestions {
+		suggester = NewSuggestionEngine(validator, directory)
+	}
+	recordValidator := NewRecordValidator(validator, directory, suggester)
+
+	// accept BFPO & overseas territory postcodes with their own classification if the profile does, & give the
+	// profile's reasons for the postcodes it rejects
+	recordValidator.SetRuleProfile(opts.profile)
+
+	withStats := opts.writeStatistics || len(opts.htmlReportPath) > 0
+	validImportRecs, invalidImportRecs := NewImportRecordGroup(), NewImportRecordGroup()
+	var stats *ValidationStatistics
+	if withStats {
+		stats = NewValidationStatistics()
+	}
+
+	// write checkpoints as the files are read & pick up from the last one, if asked for
+	checkpoints, err := newCheckpointer(inputs, opts)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	cursor := InputCursor{}
+	limit := 0
+	if checkpoints != nil {
+		cursor = checkpoints.state.Cursor
+		limit = checkpoints.state.Every
+
+		// the records validated before the checkpoint are read back rather than validated again
+		valid, invalid, err := checkpoints.load(inputs)
+		if err != nil {
+			return ImportResult{}, err
+		}
+		validImportRecs = append(validImportRecs, valid...)
+		invalidImportRecs = append(invalidImportRecs, invalid...)
+		if withStats {
+			for _, coll := range []ImportRecordGroup{valid, invalid} {
+				for _, rec := range coll {
+					stats.Add(rec)
+				}
+			}
+		}
+	}
+
+	// read & validate the files "limit" lines at a time (all of them at once when there are no checkpoints),
+	// saving a checkpoint after each set of lines
+	for !cursor.done(inputs) {
+		valid, invalid, segmentStats, err := validateInputSegment(inputs, inputFormat, &cursor, limit, recordValidator, opts.rowIdRange, withStats)
+
+		// stop before writing anything if a line could not be read, the output would be missing records
+		if err != nil {
+			return ImportResult{}, err
+		}
+
+		validImportRecs = append(validImportRecs, valid...)
+		invalidImportRecs = append(invalidImportRecs, invalid...)
+		if withStats {
+			stats.Merge(segmentStats)
+		}
+
+		if checkpoints != nil && !cursor.done(inputs) {
+			if err := checkpoints.save(cursor, valid, invalid); err != nil {
+				return ImportResult{}, err
+			}
+		}
+	}
+
+	// the column names of the first input file are used for the output files, it is read again if it was
+	// finished before the checkpoint this run resumed from
+	if inputs[0].columnNames == nil {
+		if err := readInputHeaderOnly(inputs[0], inputFormat); err != nil {
+			return ImportResult{}, err
+		}
+	}
+	columnNames := inputs[0].columnNames
+	withSources := len(inputs) > 1
+	result := ImportResult{numValid: len(validImportRecs), numInvalid: len(invalidImportRecs), numMalformed: cursor.Malformed}
 
 	// run the sorting of each group in its own routine & sync with a wait group
 	var sortRecordWG sync.WaitGroup
@@ -76,24 +279,145 @@ func main() {
 	// wait for sorting to complete
 	sortRecordWG.Wait()
 
-	// write each collection to a CSV file ----------------------------------------------------------------
-	writeOutputFiles(columnNames, validImportRecs, invalidImportRecs)
+	// look for repeated row ids (and postcodes) & write them to their own file, if asked for
+	if opts.findDuplicates || opts.findDuplicatePostcodes {
+		duplicates := findDuplicateRowIds(validImportRecs, invalidImportRecs)
+		if opts.findDuplicatePostcodes {
+			duplicates = append(duplicates, findDuplicatePostcodes(validImportRecs, invalidImportRecs)...)
+		}
+		if err := writeDuplicatesFile(opts.outputPath(DUPLICATES_FILE_NAME), duplicates, withSources); err != nil {
+			return result, &OutputError{path: opts.outputPath(DUPLICATES_FILE_NAME), err: err}
+		}
+	}
+
+	// write the per area, per district & per reason counts, if asked for
+	if opts.writeStatistics {
+		if err := writeStatisticsFiles(opts.outputPath(STATISTICS_CSV_FILE_NAME), opts.outputPath(STATISTICS_JSON_FILE_NAME), stats.Summary(opts.statisticsTopN)); err != nil {
+			return result, &OutputError{path: opts.outputPath(STATISTICS_CSV_FILE_NAME), err: err}
+		}
+	}
+
+	// report the ranges of row ids missing from the input file, if asked for
+	if opts.auditRowIdGaps {
+		if err := writeRowIdGapsFile(opts.outputPath(ROW_ID_GAPS_FILE_NAME), findRowIdGaps(validImportRecs, invalidImportRecs)); err != nil {
+			return result, &OutputError{path: opts.outputPath(ROW_ID_GAPS_FILE_NAME), err: err}
+		}
+	}
+
+	// write each collection to its sink (a CSV file unless another was asked for) ------------------------
+	if err := writeOutputFiles(columnNames, validImportRecs, invalidImportRecs, withSources, validSink, invalidSink, opts); err != nil {
+		return result, err
+	}
+
+	// write every record to a SQLite database so it can be queried, if asked for
+	if len(opts.sqlitePath) > 0 {
+		if err := writeSqliteDatabase(opts.sqlitePath, validImportRecs, invalidImportRecs, withSources); err != nil {
+			return result, err
+		}
+	}
+
+	if opts.showReport {
+		printCompletionReport(startTime, len(validImportRecs), len(invalidImportRecs), cursor.Malformed)
+		printCompressedSizes(validSink, invalidSink)
+	}
+
+	// write the html report last so it can include the metadata of every output file
+	if len(opts.htmlReportPath) > 0 {
+		files := make(map[string]string)
+		for role, sink := range map[string]RecordSink{"succeeded": validSink, "failed": invalidSink} {
+			switch s := sink.(type) {
+			case *FileSink:
+				files[role] = s.path
+			case *ChunkedSink:
+				files[role+" (manifest)"] = s.manifestPath
+			}
+		}
+		for _, input := range inputs {
+			if withSources {
+				files[fmt.Sprintf("input %d", input.index+1)] = input.path
+			} else {
+				files["input"] = input.path
+			}
+		}
+		if len(opts.directoryPath) > 0 {
+			files["directory"] = opts.directoryPath
+		}
+		if opts.findDuplicates || opts.findDuplicatePostcodes {
+			files["duplicates"] = opts.outputPath(DUPLICATES_FILE_NAME)
+		}
+		if opts.auditRowIdGaps {
+			files["row id gaps"] = opts.outputPath(ROW_ID_GAPS_FILE_NAME)
+		}
+		if len(opts.sqlitePath) > 0 {
+			files["sqlite"] = opts.sqlitePath
+		}
+		if opts.writeStatistics {
+			files["statistics (csv)"] = opts.outputPath(STATISTICS_CSV_FILE_NAME)
+			files["statistics (json)"] = opts.outputPath(STATISTICS_JSON_FILE_NAME)
+		}
+		report := newHtmlReport(startTime, opts.profile, invalidImportRecs, len(validImportRecs), stats.Summary(opts.statisticsTopN), files)
+		if err := writeHtmlReport(opts.htmlReportPath, report); err != nil {
+			return result, &OutputError{path: opts.htmlReportPath, err: err}
+		}
+	}
+
+	// the run is complete so its checkpoint is no longer needed
+	if checkpoints != nil {
+		if err := checkpoints.remove(); err != nil {
+			return result, &OutputError{path: checkpoints.dir, err: err}
+		}
+	}
 
-	if showReport {
-		printCompletionReport(startTime, len(validImportRecs), len(invalidImportRecs))
+	// fail the run if too many records are invalid, the output files are still written so they can be checked
+	if err := checkInvalidRatio(result, opts.maxInvalidRatio); err != nil {
+		return result, err
 	}
+
+	return result, nil
+}
+
+// "validateInputSegment" reads up to "limit" lines (every line if "limit" is 0) in "format" from "inputs" starting
+// at "cursor", validates them & moves "cursor" past the lines read, counting the malformed rows skipped in it. The
+// error returned is the first line that could not be read, if there was one
+func validateInputSegment(inputs []*InputFile, format *InputFormat, cursor *InputCursor, limit int, val *RecordValidator, rng RowIdRange, withStats bool) (validGrp, invalidGrp ImportRecordGroup, stats *ValidationStatistics, err error) {
+
+	// we need a wait group to sync our go routines that are running in parallel, & somewhere for them to report
+	// the first line that could not be read
+	var readRecordWG sync.WaitGroup
+	var pipelineErr PipelineError
+
+	// run a number of go routines in a parallel pipelines pattern [readFromInputFiles_go -> createInputRecords_go -> validateInputRecords_go]
+	// each function does its job concurrently until there is no more work to do, the WaitGroup readRecordWG sycncronises them with main()
+	readLines_chan := readFromInputFiles_go(&readRecordWG, inputs, format, cursor, limit, &pipelineErr)
+	createdInputRecords_chan := createInputRecords_go(&readRecordWG, readLines_chan, rng, format.skipMalformed, &pipelineErr)
+	validGrp, invalidGrp, stats = validateInputRecords(createdInputRecords_chan, val, withStats)
+
+	// make the main function wait until all functions in the "readRecordWG" have completed - we need all records to be validated before sorting
+	readRecordWG.Wait()
+	cursor.Malformed += pipelineErr.Malformed()
+
+	return validGrp, invalidGrp, stats, pipelineErr.Err()
 }
 
 // "validateInputRecords" validates the input records in receives on its input chanel "in", to do this it
 // spawns 3 concurrent worker routines which each validate each recored received and places each validated
 // in a channel(valid/invalid) based on the records validity. It also spawns 2 concurrent collector routines that
-// collect records from the valid/invalid channel and places them into seperate ImportRecord slices which are returned
-func validateInputRecords(in <-chan *ImportRecord, val *RegexValidatorGroup) (validGrp, invalidGrp ImportRecordGroup) {
+// collect records from the valid/invalid channel and places them into seperate ImportRecord slices which are returned.
+// Each record is validated by the RecordValidator "val" which runs every stage of validation that was asked for.
+// When "withStats" is set the collector routines also count each record, the merged counts are returned in "stats"
+func validateInputRecords(in <-chan *ImportRecord, val *RecordValidator, withStats bool) (validGrp, invalidGrp ImportRecordGroup, stats *ValidationStatistics) {
 
 	// make output groups of import records
 	valid := NewImportRecordGroup()
 	invalid := NewImportRecordGroup()
 
+	// each collector routine keeps its own statistics so they do not need to be locked
+	var validStats, invalidStats *ValidationStatistics
+	if withStats {
+		validStats = NewValidationStatistics()
+		invalidStats = NewValidationStatistics()
+	}
+
 	// we will use these WaitGroups to avoid race conditions between the worker routines and collector routines
 	var validateWg sync.WaitGroup
 	var appendWg sync.WaitGroup
@@ -108,7 +432,7 @@ func validateInputRecords(in <-chan *ImportRecord, val *RegexValidatorGroup) (va
 		go func() {
 			// keep working as long as the input channel is open
 			for rec := range in {
-				rec.isValid = val.GroupIsStringValid(rec.postcode)
+				val.Validate(rec)
 
 				// sort the ImportRecords based on their validity
 				if rec.isValid {
@@ -128,6 +452,9 @@ func validateInputRecords(in <-chan *ImportRecord, val *RegexValidatorGroup) (va
 	go func() {
 		for rec := range validChan {
 			valid = append(valid, rec)
+			if withStats {
+				validStats.Add(rec)
+			}
 		}
 		appendWg.Done()
 	}()
@@ -136,6 +463,9 @@ func validateInputRecords(in <-chan *ImportRecord, val *RegexValidatorGroup) (va
 	go func() {
 		for rec := range invalidChan {
 			invalid = append(invalid, rec)
+			if withStats {
+				invalidStats.Add(rec)
+			}
 		}
 		appendWg.Done()
 	}()
@@ -148,13 +478,18 @@ func validateInputRecords(in <-chan *ImportRecord, val *RegexValidatorGroup) (va
 	// the function will finish when the collector routines in "appendWg" have finished
 	appendWg.Wait()
 
-	return valid, invalid
+	if withStats {
+		validStats.Merge(invalidStats)
+	}
+
+	return valid, invalid, validStats
 }
 
-// "createInputRecords_go" takes string slices that it receives on its input channel "in" creates new ImportRecord
-// structs using each string slice, then places each struct on its output channel "out". This is done concurrently
-// "createInputRecords_go" returns its output channel to the caller
-func createInputRecords_go(wg *sync.WaitGroup, in <-chan []string) <-chan *ImportRecord {
+// "createInputRecords_go" takes InputLines that it receives on its input channel "in" creates new ImportRecord
+// structs using each line's string slice, then places each struct on its output channel "out". This is done concurrently
+// "createInputRecords_go" returns its output channel to the caller. A line with a row id that is not a number or
+// not in "rng" is dropped and reported to "errs" as a ParseError, or only counted as skipped if "skipMalformed" is set
+func createInputRecords_go(wg *sync.WaitGroup, in <-chan InputLine, rng RowIdRange, skipMalformed bool, errs *PipelineError) <-chan *ImportRecord {
 	// make out output channel & increment the WaitGroup
 	wg.Add(1)
 	out := make(chan *ImportRecord, CHAN_DEFAULT_SIZE)
@@ -162,9 +497,21 @@ func createInputRecords_go(wg *sync.WaitGroup, in <-chan []string) <-chan *Impor
 	// the creation of new InputRecord structs is done in its own go routine
 	go func() {
 		// keep working as long as the input channel is open
-		for strRec := range in {
+		for line := range in {
 			// create an import records from the string slice read from the channel "in" & put it into the outchannel
-			out <- NewImportRecord(strRec)
+			rec, err := NewImportRecordInRange(line.fields, rng)
+			if err != nil {
+				// keep reading from "in" so the routine sending to it is not blocked
+				if skipMalformed {
+					errs.SkipMalformed()
+				} else {
+					errs.Set(line.location(), &ParseError{path: line.source.path, lineNumber: line.lineNumber, err: err})
+				}
+				continue
+			}
+			rec.source = line.source
+			rec.lineNumber = line.lineNumber
+			out <- rec
 		}
 		// close out output channel upon completion & signal completion to the WaitGroup
 		close(out)
@@ -175,33 +522,41 @@ func createInputRecords_go(wg *sync.WaitGroup, in <-chan []string) <-chan *Impor
 	return out
 }
 
-// "readFromInputFile_go" takes a buffered reader and reads the reader's input source line by line. As each
-// line is a record in a csv file each line is split on the comma delimiter and a string slice is  created
-// from the results of the split. Each string slice is put into its output channel "out". This is done concurrently
-// "readFromInputFile_go"
-func readFromInputFile_go(wg *sync.WaitGroup, reader *bufio.Reader) <-chan []string {
+// type that stores the fields of a line read from an input file, the file & the line number it was read from
+type InputLine struct {
+	source     *InputFile
+	lineNumber uint64
+	fields     []string
+}
+
+// returns the input file & line the line was read from
+func (line InputLine) location() RecordLocation {
+	return RecordLocation{source: line.source, lineNumber: line.lineNumber}
+}
+
+// "readFromInputFiles_go" reads the input files from "cursor" onwards, opening each in turn & reading its lines
+// with "readInputFile", the lines of every file are put into the one output channel "out". This is done
+// concurrently, reading stops once "limit" lines have been read (if it is not 0), at the first file that can not
+// be opened or at the first line that can not be read & the error is reported to "errs". "cursor" is moved past
+// each line read, it must not be used until the WaitGroup "wg" is done
+func readFromInputFiles_go(wg *sync.WaitGroup, inputs []*InputFile, format *InputFormat, cursor *InputCursor, limit int, errs *PipelineError) <-chan InputLine {
 	// make our output channel & increment the WaitGroup
 	wg.Add(1)
-	out := make(chan []string, CHAN_DEFAULT_SIZE)
-	completed := false
+	out := make(chan InputLine, CHAN_DEFAULT_SIZE)
 
-	// reading of the file runs is done in its own go routine
+	// reading of the files is done in its own go routine
 	go func() {
-		for !completed {
-			// read a record from each line in the csv file & reading complete when we hit EOF
-			line, e := reader.ReadString('\n')
-			if e == io.EOF {
-				completed = true
+		remaining := limit
+		for !cursor.done(inputs) {
+			numRead, ok := readInputFile(inputs[cursor.FileIndex], format, cursor, remaining, out, errs)
+			if !ok {
 				break
 			}
-			check(e)
-
-			// split the string at the comma and turn it into a string slice, trim any space from each string
-			res := strings.Split(line, ",")
-			record := []string{strings.TrimSpace(res[0]), strings.TrimSpace(res[1])}
-
-			// place each read record into the channel to send to consumer routine
-			out <- record
+			if limit > 0 {
+				if remaining -= numRead; remaining == 0 {
+					break
+				}
+			}
 		}
 		// close the channel when we are finished reading & signal completion to the wait group
 		close(out)
@@ -212,9 +567,177 @@ func readFromInputFile_go(wg *sync.WaitGroup, reader *bufio.Reader) <-chan []str
 	return out
 }
 
+// "readInputFile" opens the input file "input", keeps the column names from its first line (if "format" has a
+// header) & sends up to "limit" of the lines after it (all of them if "limit" is 0) to "out", starting at "cursor".
+// If reading stops before the end of the file it is left open, the next call carries on from where it stopped
+// unless "cursor" was moved elsewhere. "cursor" moves on to the next input file once the end of this one is reached.
+// The number of lines read is returned along with false if reading stopped because of an error
+func readInputFile(input *InputFile, format *InputFormat, cursor *InputCursor, limit int, out chan<- InputLine, errs *PipelineError) (int, bool) {
+	reader := input.text
+	if reader == nil || input.textOffset != cursor.Offset {
+		input.closeText()
+		var ok bool
+		if reader, ok = openInputFile(input, format, cursor, errs); !ok {
+			return 0, false
+		}
+	}
+
+	numRead, eof, ok := readInputLines(reader, input, format, cursor, limit, out, errs)
+	if !ok || eof {
+		input.closeText()
+	} else {
+		input.text, input.textOffset = reader, cursor.Offset
+	}
+	if ok && eof {
+		*cursor = InputCursor{FileIndex: cursor.FileIndex + 1, Malformed: cursor.Malformed}
+	}
+	return numRead, ok
+}
+
+// "openInputFile" opens the input file "input" & reads its header into its column names, then returns a reader of
+// its text in UTF-8 starting at "cursor" (or after the header, which "cursor" is moved to, if it is at the start of
+// the file). The file is kept in "input" until it is closed with "closeText". An error is reported to "errs" &
+// false returned if the file can not be opened or its header read
+func openInputFile(input *InputFile, format *InputFormat, cursor *InputCursor, errs *PipelineError) (*bufio.Reader, bool) {
+	file, err := os.Open(input.path)
+	if err != nil {
+		errs.Set(RecordLocation{source: input}, &InputError{path: input.path, err: err})
+		return nil, false
+	}
+	input.file = file
+
+	// the text is read in UTF-8 without any byte order mark
+	reader, err := openInputText(file, format.encoding, 0)
+	if err != nil {
+		input.closeText()
+		errs.Set(RecordLocation{source: input}, &InputError{path: input.path, err: err})
+		return nil, false
+	}
+
+	// first record in the csv file will be titles so read it and keep the result for output titles
+	headerLength, err := readInputHeader(input, format, reader)
+	if err != nil {
+		input.closeText()
+		errs.Set(RecordLocation{source: input, lineNumber: 1}, err)
+		return nil, false
+	}
+
+	// start after the header, or carry on from where the last checkpoint got to
+	if cursor.Offset == 0 {
+		cursor.Offset, cursor.LineNumber = headerLength, 0
+		if format.hasHeader {
+			cursor.LineNumber = 1
+		}
+	} else if cursor.Offset != headerLength {
+		if reader, err = openInputText(file, format.encoding, cursor.Offset); err != nil {
+			input.closeText()
+			errs.Set(RecordLocation{source: input, lineNumber: cursor.LineNumber}, &InputError{path: input.path, err: err})
+			return nil, false
+		}
+	}
+	return reader, true
+}
+
+// "readInputHeader" reads the first line of an input file from "reader" into the column names of "input" &
+// returns its length in bytes. A ParseError is returned if it does not hold two column names. Nothing is read
+// if "format" has no header, its column names are used
+func readInputHeader(input *InputFile, format *InputFormat, reader *bufio.Reader) (int64, error) {
+	if !format.hasHeader {
+		input.columnNames = format.columnNames
+		return 0, nil
+	}
+
+	header, err := readInputLine(reader)
+	if err != nil && err != io.EOF {
+		return 0, &InputError{path: input.path, err: err}
+	}
+	if err := checkLineEncoding(header); err != nil {
+		return 0, &ParseError{path: input.path, lineNumber: 1, err: err}
+	}
+
+	columnNames, err := format.split(header)
+	if err != nil {
+		return 0, &ParseError{path: input.path, lineNumber: 1, err: errors.New(format.headerError)}
+	}
+	input.columnNames = columnNames
+	return int64(len(header)), nil
+}
+
+// "readInputHeaderOnly" opens the input file "input" & reads its column names with "readInputHeader"
+func readInputHeaderOnly(input *InputFile, format *InputFormat) error {
+	file, err := os.Open(input.path)
+	if err != nil {
+		return &InputError{path: input.path, err: err}
+	}
+	defer file.Close()
+
+	reader, err := openInputText(file, format.encoding, 0)
+	if err != nil {
+		return &InputError{path: input.path, err: err}
+	}
+	_, err = readInputHeader(input, format, reader)
+	return err
+}
+
+// "readInputLines" reads "reader" line by line. Each line is a record that "format" splits into its row id &
+// postcode (on the comma delimiter of a csv file) and a string slice is created from the results of the split.
+// Each string slice is put into "out" along with its input file "source" & line number. "cursor" holds the
+// offset & number of the last line read before "reader" & is moved past each line read. Lines may end in "\n",
+// "\r\n" or "\r" & the last line need not end in one. Blank lines are skipped unless "format" reports them as
+// malformed, malformed rows are skipped & counted in "errs" if "format" says so. Reading stops after "limit" records
+// (if it is not 0), at the end of the reader (eof is true) or at the first line that can not be split or if the
+// reader fails, the error is then reported to "errs" & ok is false
+func readInputLines(reader *bufio.Reader, source *InputFile, format *InputFormat, cursor *InputCursor, limit int, out chan<- InputLine, errs *PipelineError) (numRead int, eof, ok bool) {
+	for limit == 0 || numRead < limit {
+		// read a record from each line in the csv file & reading complete when we hit EOF
+		line, e := readInputLine(reader)
+		if e == io.EOF {
+			return numRead, true, true
+		}
+		lineNumber := cursor.LineNumber + 1
+		if e != nil {
+			errs.Set(RecordLocation{source: source, lineNumber: lineNumber}, &InputError{path: source.path, err: e})
+			return numRead, false, false
+		}
+
+		// a blank line holds no record, it is passed over like any other line so line numbers & offsets stay right
+		blank := len(strings.TrimSpace(line)) == 0
+		if blank && !format.blankLinesMalformed {
+			cursor.Offset += int64(len(line))
+			cursor.LineNumber = lineNumber
+			continue
+		}
+
+		// split the string at the comma (or as the format says) and turn it into a string slice, trim any space from
+		// each string. A line that could not be decoded is malformed whatever its fields
+		err := checkLineEncoding(line)
+		var record []string
+		if err == nil && blank {
+			err = errBlankLine
+		} else if err == nil {
+			record, err = format.split(line)
+		}
+		if err != nil && !format.skipMalformed {
+			errs.Set(RecordLocation{source: source, lineNumber: lineNumber}, &ParseError{path: source.path, lineNumber: lineNumber, err: err})
+			return numRead, false, false
+		}
+		cursor.Offset += int64(len(line))
+		cursor.LineNumber = lineNumber
+		if err != nil {
+			errs.SkipMalformed()
+			continue
+		}
+
+		// place each read record into the channel to send to consumer routine
+		out <- InputLine{source: source, lineNumber: lineNumber, fields: record}
+		numRead++
+	}
+	return numRead, false, true
+}
+
 // "printCompletionReport" print out a short report consisting of how many records are valid, invalid,
-// the total number of records total execution time & rate of record processing
-func printCompletionReport(startTime time.Time, numValid, numInvalid int) {
+// the total number of records, the malformed rows skipped (if any) total execution time & rate of record processing
+func printCompletionReport(startTime time.Time, numValid, numInvalid, numMalformed int) {
 	// get time since beginning
 	elapsed := time.Since(startTime)
 
@@ -226,99 +749,275 @@ func printCompletionReport(startTime time.Time, numValid, numInvalid int) {
 	fmt.Printf("Total records: %d\n", numValid+numInvalid)
 	fmt.Printf("Succeeded: %d\n", numValid)
 	fmt.Printf("Failed: %d\n", numInvalid)
+	if numMalformed > 0 {
+		fmt.Printf("Malformed rows skipped: %d\n", numMalformed)
+	}
 	fmt.Println("-------------------------------------")
 	fmt.Printf("Took: %s\n", elapsed)
 	fmt.Printf("Speed: %.2f records per second\n", speed)
 	fmt.Println("-------------------------------------")
 }
 
-// "writeOutputFiles" takes the column name read from the original input csv file  and ImportRecord slices
-// containing the valid and invalid records. These are used to create the "succeeded_validation.csv" and
-//  "failed_validation.csv" file. Each file is written to concurrently.
-func writeOutputFiles(columnNames []string, validRecs, invalidRecs []*ImportRecord) {
+// "printCompressedSizes" prints the size of each compressed output file & of the text it holds, nothing is printed
+// for sinks that are not compressed files
+func printCompressedSizes(sinks ...RecordSink) {
+	for _, sink := range sinks {
+		for _, fileSink := range fileSinks(sink) {
+			if fileSink.compress == nil {
+				continue
+			}
+			text, compressed := fileSink.sizes()
+			ratio := 0.0
+			if text > 0 {
+				ratio = 100 * float64(compressed) / float64(text)
+			}
+			fmt.Printf("%s: %d bytes compressed, %d bytes uncompressed (%.1f%%)\n", fileSink.path, compressed, text, ratio)
+		}
+	}
+}
 
-	// write to both output files in parallel & use WaitGroup to sync
+// "writeOutputFiles" takes the column name read from the original input csv file  and ImportRecord slices
+// containing the valid and invalid records. The valid records are written to "validSink" & the invalid records
+// to "invalidSink" (by default the "succeeded_validation.csv" and "failed_validation.csv" files), each sink is
+// written to concurrently. The options "opts" decide which extra columns (postcode components, directory status,
+// classification) are written for valid records and which (suggested corrections) are written for invalid ones.
+// When "withSources" is set both gain a column with the path of the input file each record was read from. The
+// first error of either sink is returned (an OutputError for the sinks that write files)
+func writeOutputFiles(columnNames []string, validRecs, invalidRecs []*ImportRecord, withSources bool, validSink, invalidSink RecordSink, opts *ProgramOptions) error {
+
+	// write to both sinks in parallel & use WaitGroup to sync, each routine keeps its own error
 	var writerWG sync.WaitGroup
+	var validErr, invalidErr error
 
 	writerWG.Add(2)
 	go func() {
 		defer writerWG.Done()
-		// create a valid record output file & buffered writer to create said file
-		validOutfile, err := os.Create("succeeded_validation.csv")
-		check(err)
-		validRecWriter := bufio.NewWriter(validOutfile)
-
-		// write the column names first
-		_, err = fmt.Fprintf(validRecWriter, "%s,%s\n", columnNames[0], columnNames[1])
-		check(err)
-
-		// write each record using our writer
-		for _, element := range validRecs {
-			temp := fmt.Sprintf("%d,%s", element.rowId, element.postcode)
-			_, e := fmt.Fprintln(validRecWriter, temp)
-			check(e)
-		}
-
-		// flush & close file now we are finished
-		validRecWriter.Flush()
-		if e := validOutfile.Close(); e != nil {
-			check(e)
-		}
+		validErr = writeRecords(validSink, validOutputColumns(columnNames, withSources, opts), validRecs,
+			func(rec *ImportRecord) []interface{} { return validOutputValues(rec, withSources, opts) })
 	}()
 
 	go func() {
 		defer writerWG.Done()
-		// create a invalid record output file & buffered writer to create said file
-		invalidOutfile, err := os.Create("failed_validation.csv")
-		check(err)
-		invalidRecWriter := bufio.NewWriter(invalidOutfile)
+		invalidErr = writeRecords(invalidSink, invalidOutputColumns(columnNames, withSources, opts), invalidRecs,
+			func(rec *ImportRecord) []interface{} { return invalidOutputValues(rec, withSources, opts) })
+	}()
 
-		// write the column names first
-		_, err = fmt.Fprintf(invalidRecWriter, "%s,%s\n", columnNames[0], columnNames[1])
-		check(err)
+	writerWG.Wait()
 
-		// write each record using our writer
-		for _, element := range invalidRecs {
-			temp := fmt.Sprintf("%d,%s", element.rowId, element.postcode)
-			_, e := fmt.Fprintln(invalidRecWriter, temp)
-			check(e)
-		}
+	if validErr != nil {
+		return validErr
+	}
+	return invalidErr
+}
+
+// "validOutputColumns" returns the names of the columns written for valid records, the row id & postcode columns
+// of the input file followed by the extra columns asked for in "opts"
+func validOutputColumns(columnNames []string, withSources bool, opts *ProgramOptions) []string {
+	columns := []string{columnNames[0], columnNames[1]}
+	if opts.showComponents {
+		columns = append(columns, POSTCODE_COMPONENT_COLUMNS...)
+	}
+	if len(opts.directoryPath) > 0 {
+		columns = append(columns, DIRECTORY_STATUS_COLUMNS...)
+	}
+	if profileAcceptsNonGeographic(opts.profile) {
+		columns = append(columns, CLASSIFICATION_COLUMN)
+	}
+	if withSources {
+		columns = append(columns, SOURCE_FILE_COLUMN)
+	}
+	return columns
+}
 
-		// flush & close file now we are finished
-		invalidRecWriter.Flush()
-		if e := invalidOutfile.Close(); e != nil {
-			check(e)
+// "validOutputValues" returns the value of each of the "validOutputColumns" for the valid record "rec"
+func validOutputValues(rec *ImportRecord, withSources bool, opts *ProgramOptions) []interface{} {
+	values := []interface{}{rec.rowId, rec.postcode}
+	if opts.showComponents {
+		for _, component := range rec.components.columns() {
+			values = append(values, component)
 		}
-	}()
+	}
+	if len(opts.directoryPath) > 0 {
+		values = append(values, rec.directoryStatus.String(), rec.terminated)
+	}
+	if profileAcceptsNonGeographic(opts.profile) {
+		values = append(values, rec.class.String())
+	}
+	if withSources {
+		values = append(values, rec.source.path)
+	}
+	return values
+}
 
-	writerWG.Wait()
+// "invalidOutputColumns" returns the names of the columns written for invalid records, the row id & postcode
+// columns of the input file followed by the extra columns asked for in "opts"
+func invalidOutputColumns(columnNames []string, withSources bool, opts *ProgramOptions) []string {
+	columns := []string{columnNames[0], columnNames[1]}
+	if opts.showSuggestions {
+		columns = append(columns, SUGGESTION_COLUMNS...)
+	}
+	if withSources {
+		columns = append(columns, SOURCE_FILE_COLUMN)
+	}
+	return columns
+}
+
+// "invalidOutputValues" returns the value of each of the "invalidOutputColumns" for the invalid record "rec"
+func invalidOutputValues(rec *ImportRecord, withSources bool, opts *ProgramOptions) []interface{} {
+	values := []interface{}{rec.rowId, rec.postcode}
+	if opts.showSuggestions {
+		values = append(values, rec.suggestion, rec.confidence)
+	}
+	if withSources {
+		values = append(values, rec.source.path)
+	}
+	return values
+}
+
+// type that stores the arguments given on the command line
+type ProgramOptions struct {
+	paths                  []string
+	perInput               bool
+	showReport             bool
+	showComponents         bool
+	directoryPath          string
+	showSuggestions        bool
+	profile                string
+	findDuplicates         bool
+	findDuplicatePostcodes bool
+	rowIdRange             RowIdRange
+	auditRowIdGaps         bool
+	writeStatistics        bool
+	statisticsTopN         int
+	htmlReportPath         string
+	sqlitePath             string
+	compress               string
+	maxRowsPerFile         int
+	maxBytesPerFile        int64
+	outputDir              string
+	maxInvalidRatio        float64
+	checkpointEvery        int
+	inputFormat            string
+	encoding               string
+	blankLines             string
+	malformedRows          string
+	outputFormat           string
+	succeededSink          string
+	failedSink             string
+	jsonFields             JsonFieldNames
+	resume                 bool
+	profiling              ProfilingOptions
+
+	// called after each checkpoint of the run is written (see Checkpointer), only set by tests to stop a run part way
+	afterCheckpoint func(state *CheckpointState) error
+}
+
+// "outputPath" returns the location of the output file named "name" in the output directory
+func (opts *ProgramOptions) outputPath(name string) string {
+	return filepath.Join(opts.outputDir, name)
 }
 
 // "getCommandLineArgs" returns what arguments were given on the command line. It will do some error checking
 // to terminate the program if invalid arguments are given
-func getCommandLineArgs() (string, bool) {
-	var path string
-	flag.StringVar(&path, "file", "", "the location of the .csv file")
-
-	var showReport bool
-	flag.BoolVar(&showReport, "report", false, "turn on to show a short report upon completion")
+func getCommandLineArgs() *ProgramOptions {
+	opts := &ProgramOptions{}
+	var patterns stringListFlag
+
+	flag.Var(&patterns, "file", "the location of a .csv file, a directory of .csv files or a glob pattern, can be given more than once (paths after the flags are also read)")
+	flag.BoolVar(&opts.perInput, "per-input", false, "turn on to write the output of each input file to its own directory inside the output directory, rather than merging them")
+	flag.BoolVar(&opts.showReport, "report", false, "turn on to show a short report upon completion")
+	flag.BoolVar(&opts.showComponents, "components", false, "turn on to write the parsed postcode components as extra columns in succeeded_validation.csv")
+	flag.StringVar(&opts.directoryPath, "directory", "", "the location of a postcode directory .csv file (ONSPD or Code-Point Open) used to check valid postcodes exist")
+	flag.BoolVar(&opts.showSuggestions, "suggest", false, "turn on to write a suggested correction & confidence score as extra columns in failed_validation.csv")
+	flag.StringVar(&opts.profile, "profile", PROFILE_BRIEF_2017, "the rule profile used to validate postcodes, one of: "+strings.Join(ruleProfileNames(), ", "))
+	flag.BoolVar(&opts.findDuplicates, "duplicates", false, "turn on to write row ids found on more than one line to "+DUPLICATES_FILE_NAME)
+	flag.BoolVar(&opts.findDuplicatePostcodes, "duplicate-postcodes", false, "turn on to also write postcodes found on more than one line to "+DUPLICATES_FILE_NAME+" (implies -duplicates)")
+	flag.Uint64Var(&opts.rowIdRange.min, "min-row-id", ROW_ID_RANGE_DEFAULT.min, "the smallest row id allowed, the program stops if a smaller one is found")
+	flag.Uint64Var(&opts.rowIdRange.max, "max-row-id", ROW_ID_RANGE_DEFAULT.max, "the largest row id allowed, the program stops if a larger one is found")
+	flag.BoolVar(&opts.auditRowIdGaps, "audit-gaps", false, "turn on to write the ranges of row ids missing from the input file to "+ROW_ID_GAPS_FILE_NAME)
+	flag.BoolVar(&opts.writeStatistics, "stats", false, "turn on to write counts by postcode area, district & failure reason to "+STATISTICS_CSV_FILE_NAME+" & "+STATISTICS_JSON_FILE_NAME)
+	flag.IntVar(&opts.statisticsTopN, "stats-top", STATISTICS_TOP_N_DEFAULT, "the number of most frequent invalid values written with -stats")
+	flag.StringVar(&opts.htmlReportPath, "html-report", "", "the location to write a self-contained .html report to at the end of the run")
+	flag.StringVar(&opts.sqlitePath, "sqlite", "", "the location to write a SQLite database of every record to, needs the "+SQLITE_COMMAND+" command line tool")
+	flag.StringVar(&opts.outputDir, "output-dir", ".", "the directory the output files are written to, it must already exist")
+	flag.Float64Var(&opts.maxInvalidRatio, "max-invalid-ratio", 1, fmt.Sprintf("the largest share (0 to 1) of records that may be invalid, the program exits with code %d if there are more", EXIT_INVALID_RATIO))
+	flag.IntVar(&opts.checkpointEvery, "checkpoint", 0, "turn on to write a checkpoint to the output directory after every N rows, so an interrupted run can be carried on with -resume")
+	flag.BoolVar(&opts.resume, "resume", false, "turn on to carry on from the last checkpoint in the output directory, if there is one")
+	flag.StringVar(&opts.inputFormat, "input-format", FORMAT_CSV, "the format of the input files, one of: "+strings.Join(inputFormatNames(), ", "))
+	flag.StringVar(&opts.blankLines, "blank-lines", BLANK_LINES_SKIP, "what is done with a blank line of an input file: "+BLANK_LINES_SKIP+" it or report it as malformed ("+BLANK_LINES_ERROR+")")
+	flag.StringVar(&opts.malformedRows, "malformed-rows", MALFORMED_ROWS_ERROR, "what is done with a row that can not be read as a row id & postcode: stop with exit code 3 ("+MALFORMED_ROWS_ERROR+") or "+MALFORMED_ROWS_SKIP+" it & count it in the completion report")
+	flag.StringVar(&opts.encoding, "encoding", ENCODING_UTF8, "the character encoding of input files without a byte order mark, one of: "+strings.Join(textEncodingNames(), ", "))
+	flag.StringVar(&opts.outputFormat, "output-format", FORMAT_CSV, "the format the output files are written in, one of: "+strings.Join(outputFormatNames(), ", "))
+	flag.StringVar(&opts.compress, "compress", COMPRESS_NONE, "the compression of succeeded_validation & failed_validation, one of: "+COMPRESS_NONE+", "+COMPRESS_GZIP)
+	flag.IntVar(&opts.maxRowsPerFile, "max-rows-per-file", 0, "the most records written to each output file, more are split over numbered files listed in a manifest (default 0, no limit)")
+	flag.Int64Var(&opts.maxBytesPerFile, "max-bytes-per-file", 0, "the most bytes (before compression) written to each output file, more are split over numbered files listed in a manifest (default 0, no limit)")
+	flag.StringVar(&opts.succeededSink, "succeeded-sink", "", "where valid records are written, one of: "+strings.Join(recordSinkNames(), ", ")+" (default -output-format)")
+	flag.StringVar(&opts.failedSink, "failed-sink", "", "where invalid records are written, one of: "+strings.Join(recordSinkNames(), ", ")+" (default -output-format)")
+	flag.StringVar(&opts.jsonFields.id, "json-id-field", JSON_ID_FIELD_DEFAULT, "the name of the JSON field holding the row id, for -input-format jsonl & -output-format jsonl")
+	flag.StringVar(&opts.jsonFields.postcode, "json-postcode-field", JSON_POSTCODE_FIELD_DEFAULT, "the name of the JSON field holding the postcode, for -input-format jsonl & -output-format jsonl")
+	flag.StringVar(&opts.profiling.cpuProfilePath, "cpuprofile", "", "the location to write a cpu profile of the run to, read it with \"go tool pprof\"")
+	flag.StringVar(&opts.profiling.memProfilePath, "memprofile", "", "the location to write a heap profile taken at the end of the run to, read it with \"go tool pprof\"")
+	flag.StringVar(&opts.profiling.tracePath, "trace", "", "the location to write an execution trace of the run to, read it with \"go tool trace\"")
 
 	flag.Parse()
 
-	if len(path) == 0 {
-		errorExit("No path to or name of a .csv file was provided", 1)
+	if opts.rowIdRange.min > opts.rowIdRange.max || opts.rowIdRange.max > ROW_ID_RANGE_DEFAULT.max {
+		errorExit(fmt.Sprintf("The row id range must have -min-row-id <= -max-row-id <= %d", ROW_ID_RANGE_DEFAULT.max), EXIT_USAGE)
+	}
+
+	if opts.maxRowsPerFile < 0 || opts.maxBytesPerFile < 0 {
+		errorExit("-max-rows-per-file & -max-bytes-per-file must not be negative", EXIT_USAGE)
+	}
+
+	if opts.checkpointEvery < 0 {
+		errorExit("-checkpoint must not be negative", EXIT_USAGE)
+	}
+
+	if opts.maxInvalidRatio < 0 || opts.maxInvalidRatio > 1 {
+		errorExit("-max-invalid-ratio must be between 0 and 1", EXIT_USAGE)
+	}
+
+	inputFormat, err := newInputFormat(opts.inputFormat, opts.jsonFields)
+	if err != nil {
+		errorExit(err.Error(), EXIT_USAGE)
+	}
+	if err := checkEncoding(opts.encoding); err != nil {
+		errorExit(err.Error(), EXIT_USAGE)
+	}
+	if err := checkBlankLines(opts.blankLines); err != nil {
+		errorExit(err.Error(), EXIT_USAGE)
+	}
+	if err := checkMalformedRows(opts.malformedRows); err != nil {
+		errorExit(err.Error(), EXIT_USAGE)
+	}
+	if _, err := newOutputFormat(opts.outputFormat, opts.jsonFields); err != nil {
+		errorExit(err.Error(), EXIT_USAGE)
+	}
+	if _, _, err := newOutputSinks(opts); err != nil {
+		errorExit(err.Error(), EXIT_USAGE)
+	}
+	if len(opts.sqlitePath) > 0 || opts.succeededSink == SINK_SQLITE || opts.failedSink == SINK_SQLITE {
+		if _, err := exec.LookPath(SQLITE_COMMAND); err != nil {
+			errorExit(fmt.Sprintf("The %s command line tool must be installed to write SQLite databases: %s", SQLITE_COMMAND, err), EXIT_USAGE)
+		}
+	}
+
+	patterns = append(patterns, flag.Args()...)
+	if len(patterns) == 0 {
+		errorExit(fmt.Sprintf("No path to or name of a %s file was provided", inputFormat.extension), EXIT_USAGE)
 	}
 
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		errStr := fmt.Sprintf("The file or path provided does not exist: \"%s\"", path)
-		errorExit(errStr, 1)
+	paths, err := expandInputPaths(patterns, inputFormat.extension)
+	if err != nil {
+		errorExit(err.Error(), exitCodeForError(err))
 	}
+	opts.paths = paths
 
-	if filepath.Ext(path) != ".csv" {
-		errorExit("File must have the extension .csv", 1)
+	if info, err := os.Stat(opts.outputDir); err != nil || !info.IsDir() {
+		errorExit(fmt.Sprintf("The output directory provided does not exist: \"%s\"", opts.outputDir), EXIT_IO_FAILURE)
 	}
 
-	return path, showReport
+	return opts
 }
 
 // "createMainRegexValidatorGroup" uses the regex given in the brief to create and return a RegexValidatorGroup
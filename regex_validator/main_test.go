@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// End-to-end tests that run the whole import on the fixture files in testdata/golden. Each case has its own
+// directory holding an "input.csv" (and any other input it needs), the files the import is expected to write
+// are kept in its "expected" directory and compared byte for byte. When the output changes on purpose the
+// expected files can be rewritten with: go test -run Test_runImport__Golden -update
+var updateGoldens = flag.Bool("update", false, "rewrite the expected output files of the golden tests")
+
+// the directory holding a directory for each golden test case
+const GOLDEN_DIR = "testdata/golden"
+
+// the golden test cases, "options" sets the options of the run on top of the defaults
+var goldenCases = []struct {
+	name    string
+	options func(opts *ProgramOptions, dir string)
+}{
+	{"part1_table", func(opts *ProgramOptions, dir string) {}},
+	{"all_columns", func(opts *ProgramOptions, dir string) {
+		opts.showComponents = true
+		opts.showSuggestions = true
+		opts.profile = PROFILE_BRIEF_2017_NON_GEOGRAPHIC
+		opts.directoryPath = filepath.Join(dir, "directory.csv")
+	}},
+	{"royalmail_profile", func(opts *ProgramOptions, dir string) {
+		opts.profile = PROFILE_ROYAL_MAIL_CURRENT
+	}},
+	{"duplicates_and_gaps", func(opts *ProgramOptions, dir string) {
+		opts.findDuplicatePostcodes = true
+		opts.auditRowIdGaps = true
+	}}, {"multiple_inputs", func(opts *ProgramOptions, dir string) {
+		paths, err := expandInputPaths([]string{filepath.Join(dir, "input.csv"), filepath.Join(dir, "shards")}, ".csv")
+		check(err)
+		opts.paths = paths
+		opts.findDuplicatePostcodes = true
+	}},
+}
+
+// "newDefaultProgramOptions" returns the options used when no optional flags are given
+func newDefaultProgramOptions(path, outputDir string) *ProgramOptions {
+	return &ProgramOptions{
+		paths:           []string{path},
+		profile:         PROFILE_BRIEF_2017,
+		rowIdRange:      ROW_ID_RANGE_DEFAULT,
+		statisticsTopN:  STATISTICS_TOP_N_DEFAULT,
+		outputDir:       outputDir,
+		maxInvalidRatio: 1,
+		inputFormat:     FORMAT_CSV,
+		encoding:        ENCODING_UTF8,
+		blankLines:      BLANK_LINES_SKIP,
+		malformedRows:   MALFORMED_ROWS_ERROR,
+		outputFormat:    FORMAT_CSV,
+		jsonFields:      JsonFieldNames{id: JSON_ID_FIELD_DEFAULT, postcode: JSON_POSTCODE_FIELD_DEFAULT},
+		compress:        COMPRESS_NONE,
+	}
+}
+
+// "listFiles" returns the sorted names of the files in "dir", nil if it does not exist
+func listFiles(t *testing.T, dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expected: every file written by the import matches its expected file byte for byte, and no file is
+// missing or extra
+func Test_runImport__Golden(t *testing.T) {
+	for _, element := range goldenCases {
+		t.Run(element.name, func(t *testing.T) {
+			dir := filepath.Join(GOLDEN_DIR, element.name)
+			expectedDir := filepath.Join(dir, "expected")
+			outputDir, err := ioutil.TempDir("", "golden")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(outputDir)
+
+			opts := newDefaultProgramOptions(filepath.Join(dir, "input.csv"), outputDir)
+			element.options(opts, dir)
+			if _, err := runImport(time.Now(), opts); err != nil {
+				t.Fatal(err)
+			}
+
+			written := listFiles(t, outputDir)
+
+			if *updateGoldens {
+				check(os.RemoveAll(expectedDir))
+				check(os.MkdirAll(expectedDir, 0755))
+				for _, name := range written {
+					data, err := ioutil.ReadFile(filepath.Join(outputDir, name))
+					check(err)
+					check(ioutil.WriteFile(filepath.Join(expectedDir, name), data, 0644))
+				}
+				return
+			}
+
+			expected := listFiles(t, expectedDir)
+			if fmt.Sprint(written) != fmt.Sprint(expected) {
+				error := fmt.Sprintf("Given case: %s, Expected files: %v   got: %v", element.name, expected, written)
+				t.Fatal(error)
+			}
+
+			for _, name := range written {
+				got, err := ioutil.ReadFile(filepath.Join(outputDir, name))
+				check(err)
+				want, err := ioutil.ReadFile(filepath.Join(expectedDir, name))
+				check(err)
+
+				if !bytes.Equal(got, want) {
+					error := fmt.Sprintf("Given case: %s, Expected %s:\n%s\n   got:\n%s", element.name, name, want, got)
+					t.Error(error)
+				}
+			}
+		})
+	}
+}
+
+// "writeTempInput" writes "contents" to an "input.csv" file in a new temporary directory and returns the path
+// of the file & the directory, which should be removed by the caller
+func writeTempInput(t *testing.T, contents string) (path, dir string) {
+	dir, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(dir, "input.csv")
+	check(ioutil.WriteFile(path, []byte(contents), 0644))
+	return path, dir
+}
+
+// expected: a line that is not a "row_id,postcode" pair stops the run with a ParseError for the first such line
+// and no output files are written
+func Test_runImport__MalformedRow(t *testing.T) {
+
+	testCases := []struct {
+		contents string
+		line     uint64
+	}{
+		{"row_id,postcode\n1,EC1A 1BB\n2 EC1A 1BB\n3,EC1A 1BB\n", 3},
+		{"row_id,postcode\n1,EC1A 1BB\nrow2,EC1A 1BB\n3,EC1A 1BB\n", 3},
+		{"row_id,postcode\n1,EC1A 1BB\n2,EC1A 1BB\n-3,EC1A 1BB\n4 EC1A 1BB\n", 4},
+		{"row_id postcode\n1,EC1A 1BB\n", 1},
+	}
+
+	for _, element := range testCases {
+		path, dir := writeTempInput(t, element.contents)
+		defer os.RemoveAll(dir)
+
+		_, err := runImport(time.Now(), newDefaultProgramOptions(path, dir))
+		parseErr, ok := err.(*ParseError)
+
+		if !ok || parseErr.lineNumber != element.line || exitCodeForError(err) != EXIT_MALFORMED {
+			error := fmt.Sprintf("Given file: %q, Expected: a ParseError on line %d   got: %v", element.contents, element.line, err)
+			t.Error(error)
+		}
+		if files := listFiles(t, dir); len(files) != 1 {
+			error := fmt.Sprintf("Given file: %q, Expected: no output files   got: %v", element.contents, files)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: a missing input file is an InputError & an output directory that can not be written to is an OutputError
+func Test_runImport__IOFailure(t *testing.T) {
+	path, dir := writeTempInput(t, "row_id,postcode\n1,EC1A 1BB\n")
+	defer os.RemoveAll(dir)
+
+	_, err := runImport(time.Now(), newDefaultProgramOptions(filepath.Join(dir, "missing.csv"), dir))
+	if _, ok := err.(*InputError); !ok || exitCodeForError(err) != EXIT_IO_FAILURE {
+		error := fmt.Sprintf("Given a missing input file, Expected: an InputError   got: %v", err)
+		t.Error(error)
+	}
+
+	_, err = runImport(time.Now(), newDefaultProgramOptions(path, filepath.Join(dir, "missing")))
+	if _, ok := err.(*OutputError); !ok || exitCodeForError(err) != EXIT_IO_FAILURE {
+		error := fmt.Sprintf("Given a missing output directory, Expected: an OutputError   got: %v", err)
+		t.Error(error)
+	}
+}
+
+// expected: a ValidationError only when the share of invalid records is above the threshold, the output files
+// are written either way
+func Test_runImport__MaxInvalidRatio(t *testing.T) {
+	// 1 of 4 records is invalid
+	path, dir := writeTempInput(t, "row_id,postcode\n1,EC1A 1BB\n2,LS44PL\n3,M1 1AE\n4,B33 8TH\n")
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		threshold float64
+		expected  bool
+	}{
+		{1, false},
+		{0.25, false},
+		{0.2, true},
+		{0, true},
+	}
+
+	for _, element := range testCases {
+		opts := newDefaultProgramOptions(path, dir)
+		opts.maxInvalidRatio = element.threshold
+
+		result, err := runImport(time.Now(), opts)
+		_, failed := err.(*ValidationError)
+
+		if failed != element.expected || (err != nil && !failed) || result.numValid != 3 || result.numInvalid != 1 {
+			error := fmt.Sprintf("Given threshold: %.2f, Expected error: %t   got: %v (%+v)", element.threshold, element.expected, err, result)
+			t.Error(error)
+		}
+		if files := listFiles(t, dir); len(files) != 3 {
+			error := fmt.Sprintf("Given threshold: %.2f, Expected: the output files to be written   got: %v", element.threshold, files)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: each input is written to its own directory & the results are added together
+func Test_runImportPerInput(t *testing.T) {
+	dir := GOLDEN_DIR + "/multiple_inputs"
+	paths, err := expandInputPaths([]string{filepath.Join(dir, "input.csv"), filepath.Join(dir, "shards")}, ".csv")
+	check(err)
+
+	outputDir, err := ioutil.TempDir("", "per-input")
+	check(err)
+	defer os.RemoveAll(outputDir)
+
+	opts := newDefaultProgramOptions("", outputDir)
+	opts.paths = paths
+	result, err := runImportPerInput(time.Now(), opts)
+
+	if err != nil || result.numValid != 5 || result.numInvalid != 3 {
+		error := fmt.Sprintf("Given the multiple_inputs files, Expected: 5 succeeded & 3 failed   got: %+v (error: %v)", result, err)
+		t.Error(error)
+	}
+
+	expected := map[string]string{
+		"input/succeeded_validation.csv": "row_id,postcode\n5,EC1A 1BB\n9,M1 1AE\n",
+		"input/failed_validation.csv":    "row_id,postcode\n2,LS44PL\n",
+		"north/succeeded_validation.csv": "id,pc\n1,B33 8TH\n5,CR2 6XH\n",
+		"north/failed_validation.csv":    "id,pc\n7,XX XXX\n",
+		"south/succeeded_validation.csv": "id,pc\n3,DN55 1PT\n",
+		"south/failed_validation.csv":    "id,pc\n9,m1 1ae\n",
+	}
+	for name, contents := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(outputDir, name))
+		if err != nil || string(data) != contents {
+			error := fmt.Sprintf("Given file: %s, Expected: %q   got: %q (error: %v)", name, contents, data, err)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: a run stopped after a checkpoint & then resumed writes the same files as a run that was not stopped,
+// the checkpoint is removed once the run is complete
+func Test_runImport__ResumeAfterInterruption(t *testing.T) {
+	dir := GOLDEN_DIR + "/multiple_inputs"
+	paths, err := expandInputPaths([]string{filepath.Join(dir, "input.csv"), filepath.Join(dir, "shards")}, ".csv")
+	check(err)
+
+	newOptions := func(outputDir string) *ProgramOptions {
+		opts := newDefaultProgramOptions("", outputDir)
+		opts.paths = paths
+		opts.findDuplicatePostcodes = true
+		opts.writeStatistics = true
+		return opts
+	}
+
+	expectedDir, err := ioutil.TempDir("", "uninterrupted")
+	check(err)
+	defer os.RemoveAll(expectedDir)
+	_, err = runImport(time.Now(), newOptions(expectedDir))
+	check(err)
+
+	outputDir, err := ioutil.TempDir("", "resumed")
+	check(err)
+	defer os.RemoveAll(outputDir)
+
+	// stop the run at its 2nd checkpoint, as if it had crashed
+	interrupted := errors.New("interrupted")
+	opts := newOptions(outputDir)
+	opts.checkpointEvery = 2
+	opts.afterCheckpoint = func(state *CheckpointState) error {
+		if len(state.Parts) == 2 {
+			return interrupted
+		}
+		return nil
+	}
+	if _, err := runImport(time.Now(), opts); err != interrupted {
+		error := fmt.Sprintf("Given a run stopped at its 2nd checkpoint, Expected: %v   got: %v", interrupted, err)
+		t.Fatal(error)
+	}
+	if state, err := loadCheckpointState(filepath.Join(outputDir, CHECKPOINT_DIR_NAME)); err != nil || state == nil || state.Cursor.FileIndex != 1 {
+		error := fmt.Sprintf("Given a run stopped at its 2nd checkpoint, Expected: a checkpoint at the 2nd input file   got: %+v (error: %v)", state, err)
+		t.Error(error)
+	}
+
+	opts = newOptions(outputDir)
+	opts.resume = true
+	if _, err := runImport(time.Now(), opts); err != nil {
+		error := fmt.Sprintf("Given a resumed run, Expected: no error   got: %v", err)
+		t.Fatal(error)
+	}
+
+	expectedFiles, resultFiles := listFiles(t, expectedDir), listFiles(t, outputDir)
+	if !reflect.DeepEqual(resultFiles, expectedFiles) {
+		error := fmt.Sprintf("Given a resumed run, Expected files: %v   got: %v", expectedFiles, resultFiles)
+		t.Error(error)
+	}
+	for _, name := range expectedFiles {
+		expected, _ := ioutil.ReadFile(filepath.Join(expectedDir, name))
+		result, err := ioutil.ReadFile(filepath.Join(outputDir, name))
+		if err != nil || string(result) != string(expected) {
+			error := fmt.Sprintf("Given file: %s, Expected: %q   got: %q (error: %v)", name, expected, result, err)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: JSON lines & tab separated input files give the same records as the .csv file, written as JSON lines
+// with the configured field names
+func Test_runImport__InputAndOutputFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "formats")
+	check(err)
+	defer os.RemoveAll(dir)
+
+	inputs := map[string]string{
+		FORMAT_JSONL: `{"id": 2, "pc": "LS44PL"}` + "\n" + `{"pc": "EC1A 1BB", "id": "1"}` + "\n",
+		FORMAT_TSV:   "id\tpc\n2\tLS44PL\n1\tEC1A 1BB\n",
+	}
+
+	for name, contents := range inputs {
+		path := filepath.Join(dir, "input."+name)
+		check(ioutil.WriteFile(path, []byte(contents), 0644))
+
+		opts := newDefaultProgramOptions(path, dir)
+		opts.inputFormat = name
+		opts.outputFormat = FORMAT_JSONL
+		opts.jsonFields = JsonFieldNames{id: "id", postcode: "pc"}
+
+		if _, err := runImport(time.Now(), opts); err != nil {
+			error := fmt.Sprintf("Given input format: %s, Expected: no error   got: %v", name, err)
+			t.Error(error)
+		}
+
+		expected := map[string]string{
+			"succeeded_validation.jsonl": `{"id":1,"pc":"EC1A 1BB"}` + "\n",
+			"failed_validation.jsonl":    `{"id":2,"pc":"LS44PL"}` + "\n",
+		}
+		for file, contents := range expected {
+			data, err := ioutil.ReadFile(filepath.Join(dir, file))
+			if err != nil || string(data) != contents {
+				error := fmt.Sprintf("Given input format: %s & file: %s, Expected: %q   got: %q (error: %v)", name, file, contents, data, err)
+				t.Error(error)
+			}
+		}
+	}
+}
+
+// expected: a file with a byte order mark or in another encoding is read as the same UTF-8 records, a byte that is
+// not valid in the encoding is a ParseError on its line
+func Test_runImport__Encodings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "encodings")
+	check(err)
+	defer os.RemoveAll(dir)
+
+	inputs := []struct {
+		encoding string
+		contents string
+	}{
+		{ENCODING_UTF8, "\xEF\xBB\xBFrow_id,postcode\n1,EC1A 1BB\n2,Z\xC3\xBCrich\n"},
+		{ENCODING_WINDOWS_1252, "row_id,postcode\n1,EC1A 1BB\n2,Z\xFCrich\n"},
+		{ENCODING_UTF8, "\xFF\xFEr\x00o\x00w\x00_\x00i\x00d\x00,\x00p\x00o\x00s\x00t\x00c\x00o\x00d\x00e\x00\n\x00" +
+			"1\x00,\x00E\x00C\x001\x00A\x00 \x001\x00B\x00B\x00\n\x002\x00,\x00Z\x00\xFC\x00r\x00i\x00c\x00h\x00\n\x00"},
+	}
+
+	for _, input := range inputs {
+		path := filepath.Join(dir, "import_data.csv")
+		check(ioutil.WriteFile(path, []byte(input.contents), 0644))
+
+		opts := newDefaultProgramOptions(path, dir)
+		opts.encoding = input.encoding
+		if _, err := runImport(time.Now(), opts); err != nil {
+			error := fmt.Sprintf("Given input: %q, Expected: no error   got: %v", input.contents, err)
+			t.Error(error)
+		}
+
+		expected := map[string]string{
+			SUCCEEDED_FILE_NAME: "row_id,postcode\n1,EC1A 1BB\n",
+			FAILED_FILE_NAME:    "row_id,postcode\n2,Zürich\n",
+		}
+		for file, contents := range expected {
+			data, err := ioutil.ReadFile(filepath.Join(dir, file))
+			if err != nil || string(data) != contents {
+				error := fmt.Sprintf("Given input: %q & file: %s, Expected: %q   got: %q (error: %v)", input.contents, file, contents, data, err)
+				t.Error(error)
+			}
+		}
+	}
+
+	path := filepath.Join(dir, "import_data.csv")
+	check(ioutil.WriteFile(path, []byte("row_id,postcode\n1,EC1A 1BB\n2,W1A \x810AX\n"), 0644))
+	opts := newDefaultProgramOptions(path, dir)
+	opts.encoding = ENCODING_WINDOWS_1252
+
+	_, err = runImport(time.Now(), opts)
+	if parseErr, ok := err.(*ParseError); !ok || parseErr.lineNumber != 3 || parseErr.err != errInvalidEncoding {
+		error := fmt.Sprintf("Given the byte 0x81 in %s, Expected: a ParseError on line 3   got: %v", ENCODING_WINDOWS_1252, err)
+		t.Error(error)
+	}
+}
+
+// expected: a file read a segment at a time (as with -checkpoint) gives every line once & in order, the decoded text
+// being left open between segments & closed at the end of the file, & a run resumed from a segment's cursor
+// carries on from the same line
+func Test_readFromInputFiles_go__Segments(t *testing.T) {
+	path, dir := writeTempInput(t, "row_id,postcode\n1,Z\xFCrich\n2,EC1A 1BB\n3,M\xFCnchen\n4,W1A 0AX\n5,K\xF8benhavn\n")
+	defer os.RemoveAll(dir)
+	format := inputFormats[FORMAT_CSV](JsonFieldNames{})
+	format.encoding = ENCODING_LATIN1
+
+	readSegment := func(inputs []*InputFile, cursor *InputCursor) []string {
+		var wg sync.WaitGroup
+		var errs PipelineError
+		var postcodes []string
+		for line := range readFromInputFiles_go(&wg, inputs, format, cursor, 2, &errs) {
+			postcodes = append(postcodes, line.fields[1])
+		}
+		wg.Wait()
+		if errs.Err() != nil {
+			t.Fatal(errs.Err())
+		}
+		return postcodes
+	}
+
+	inputs := newInputFiles([]string{path})
+	cursor := InputCursor{}
+	var postcodes []string
+	var text *bufio.Reader
+	for i := 0; !cursor.done(inputs); i++ {
+		postcodes = append(postcodes, readSegment(inputs, &cursor)...)
+		if i == 0 {
+			text = inputs[0].text
+		}
+		if !cursor.done(inputs) && (inputs[0].text == nil || inputs[0].text != text) {
+			error := fmt.Sprintf("Given segment: %d, Expected: the text of the first segment left open   got: %p", i+1, inputs[0].text)
+			t.Error(error)
+		}
+	}
+
+	expected := []string{"Zürich", "EC1A 1BB", "München", "W1A 0AX", "København"}
+	if !reflect.DeepEqual(postcodes, expected) || inputs[0].file != nil {
+		error := fmt.Sprintf("Given 3 segments, Expected: %q & the file closed   got: %q (open: %t)", expected, postcodes, inputs[0].file != nil)
+		t.Error(error)
+	}
+
+	// a resumed run starts with its input files closed
+	inputs = newInputFiles([]string{path})
+	cursor = InputCursor{}
+	readSegment(inputs, &cursor)
+	resumed := newInputFiles([]string{path})
+	if result := readSegment(resumed, &cursor); !reflect.DeepEqual(result, expected[2:4]) {
+		error := fmt.Sprintf("Given a run resumed after 2 lines, Expected: %q   got: %q", expected[2:4], result)
+		t.Error(error)
+	}
+	closeInputFiles(inputs)
+	closeInputFiles(resumed)
+}
+
+// expected: the same records whatever the line endings, with the last line read when it has no line ending &
+// blank lines skipped, or a ParseError on the first blank line with -blank-lines error
+func Test_runImport__LineEndings(t *testing.T) {
+	testCases := []struct {
+		contents string
+		blank    uint64
+	}{
+		{"row_id,postcode\n1,EC1A 1BB\n2,LS44PL", 0},
+		{"row_id,postcode\r\n1,EC1A 1BB\r\n2,LS44PL\r\n", 0},
+		{"row_id,postcode\r1,EC1A 1BB\r2,LS44PL\r", 0},
+		{"row_id,postcode\n\n1,EC1A 1BB\n  \t\n2,LS44PL\n\n", 2},
+		{"row_id,postcode\r\n1,EC1A 1BB\r\n\r\n2,LS44PL", 3},
+		{"row_id,postcode\r1,EC1A 1BB\r \r2,LS44PL\r\n\n", 3},
+	}
+
+	expected := map[string]string{
+		SUCCEEDED_FILE_NAME: "row_id,postcode\n1,EC1A 1BB\n",
+		FAILED_FILE_NAME:    "row_id,postcode\n2,LS44PL\n",
+	}
+
+	for _, element := range testCases {
+		path, dir := writeTempInput(t, element.contents)
+		defer os.RemoveAll(dir)
+
+		opts := newDefaultProgramOptions(path, dir)
+		if _, err := runImport(time.Now(), opts); err != nil {
+			error := fmt.Sprintf("Given file: %q, Expected: no error   got: %v", element.contents, err)
+			t.Error(error)
+		}
+		for file, contents := range expected {
+			data, err := ioutil.ReadFile(filepath.Join(dir, file))
+			if err != nil || string(data) != contents {
+				error := fmt.Sprintf("Given file: %q & output: %s, Expected: %q   got: %q (error: %v)", element.contents, file, contents, data, err)
+				t.Error(error)
+			}
+		}
+
+		opts.blankLines = BLANK_LINES_ERROR
+		_, err := runImport(time.Now(), opts)
+		if parseErr, ok := err.(*ParseError); element.blank > 0 && (!ok || parseErr.lineNumber != element.blank || parseErr.err != errBlankLine) {
+			error := fmt.Sprintf("Given file: %q & -blank-lines %s, Expected: a ParseError on line %d   got: %v", element.contents, BLANK_LINES_ERROR, element.blank, err)
+			t.Error(error)
+		} else if element.blank == 0 && err != nil {
+			error := fmt.Sprintf("Given file: %q & -blank-lines %s, Expected: no error   got: %v", element.contents, BLANK_LINES_ERROR, err)
+			t.Error(error)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"regexp"
+)
+
+// type used to signal what kind of postcode a valid record holds, so that non-geographic postcodes can be
+// routed separately from normal ones
+type PostcodeClass uint8
+
+const (
+	CLASS_GEOGRAPHIC         PostcodeClass = iota // a normal postcode accepted by the main RegexValidatorGroup
+	CLASS_BFPO                                    // a British Forces Post Office number or BF1 postcode
+	CLASS_OVERSEAS_TERRITORY                      // a British Overseas Territory postcode such as "FIQQ 1ZZ"
+)
+
+// name of the extra column written to "succeeded_validation.csv" when non-geographic postcodes are accepted
+const CLASSIFICATION_COLUMN = "classification"
+
+// "String" returns the name of the class as written to the output files
+func (c PostcodeClass) String() string {
+	switch c {
+	case CLASS_BFPO:
+		return "bfpo"
+	case CLASS_OVERSEAS_TERRITORY:
+		return "overseas_territory"
+	}
+	return "geographic"
+}
+
+// type that pairs a RegexValidatorGroup with the PostcodeClass given to the postcodes it finds valid
+type PostcodeClassRule struct {
+	class     PostcodeClass
+	validator *RegexValidatorGroup
+}
+
+// create and return a pointer to a new PostcodeClassRule
+func NewPostcodeClassRule(class PostcodeClass, val *RegexValidatorGroup) *PostcodeClassRule {
+	return &PostcodeClassRule{class: class, validator: val}
+}
+
+// "createNonGeographicClassRules" creates the rules that accept the non-geographic postcodes rejected by the
+// Part 1 regex: BFPO numbers ("BFPO 1234", "BFPO C/O 123"), the BF1 postcodes used by BFPO and the British
+// Overseas Territory postcodes that follow the UK format
+func createNonGeographicClassRules() []*PostcodeClassRule {
+	// regex that matches BFPO numbers and BF1 postcodes (postcodes that match it are valid)
+	var bfpoRegex = regexp.MustCompile(`^((BFPO\s(C/O\s)?[0-9]{1,4})|(BF1\s[0-9][ABD-HJLNP-UW-Z]{2}))$`)
+	var bfpoValidator = NewRegexValidatorGroup()
+	bfpoValidator.AddRegexValidator(NewRegexValidator(bfpoRegex, MATCH_MEANS_VALID))
+
+	// regex that matches the overseas territory postcodes (postcodes that match it are valid)
+	var overseasRegex = regexp.MustCompile(`^((ASCN|BBND|BIQQ|FIQQ|PCRN|SIQQ|STHL|TDCU|TKCA)\s1ZZ|GX11\s1AA)$`)
+	var overseasValidator = NewRegexValidatorGroup()
+	overseasValidator.AddRegexValidator(NewRegexValidator(overseasRegex, MATCH_MEANS_VALID))
+
+	return []*PostcodeClassRule{
+		NewPostcodeClassRule(CLASS_BFPO, bfpoValidator),
+		NewPostcodeClassRule(CLASS_OVERSEAS_TERRITORY, overseasValidator),
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// "newNonGeographicRecordValidator" creates a RecordValidator that accepts non-geographic postcodes
+func newNonGeographicRecordValidator() *RecordValidator {
+	recVal := NewRecordValidator(createMainRegexValidatorGroup(), nil, nil)
+	for _, rule := range createNonGeographicClassRules() {
+		recVal.AddClassRule(rule)
+	}
+	return recVal
+}
+
+// expected: each postcode is valid and given the expected class
+// validate non-geographic & geographic postcodes with the class rules added
+func Test_RecordValidator_Validate__NonGeographicClasses(t *testing.T) {
+
+	recVal := newNonGeographicRecordValidator()
+
+	testCases := []struct {
+		postcode string
+		class    PostcodeClass
+	}{
+		{"BFPO 1234", CLASS_BFPO},
+		{"BFPO 57", CLASS_BFPO},
+		{"BFPO C/O 123", CLASS_BFPO},
+		{"BF1 3AA", CLASS_BFPO},
+		{"ASCN 1ZZ", CLASS_OVERSEAS_TERRITORY},
+		{"BIQQ 1ZZ", CLASS_OVERSEAS_TERRITORY},
+		{"FIQQ 1ZZ", CLASS_OVERSEAS_TERRITORY},
+		{"STHL 1ZZ", CLASS_OVERSEAS_TERRITORY},
+		{"TKCA 1ZZ", CLASS_OVERSEAS_TERRITORY},
+		{"SIQQ 1ZZ", CLASS_OVERSEAS_TERRITORY},
+		{"PCRN 1ZZ", CLASS_OVERSEAS_TERRITORY},
+		{"GX11 1AA", CLASS_OVERSEAS_TERRITORY},
+		{"EC1A 1BB", CLASS_GEOGRAPHIC},
+	}
+
+	for _, element := range testCases {
+		rec := &ImportRecord{postcode: element.postcode}
+		recVal.Validate(rec)
+
+		if !rec.isValid || rec.class != element.class {
+			error := fmt.Sprintf("Given postcode: %s, Expected: valid %s   got: %t %s", element.postcode, element.class, rec.isValid, rec.class)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: false
+// validate non-geographic postcodes without the class rules added (the Part 1 behaviour)
+func Test_RecordValidator_Validate__NonGeographicRejectedByDefault(t *testing.T) {
+
+	recVal := NewRecordValidator(createMainRegexValidatorGroup(), nil, nil)
+
+	for _, postcode := range []string{"BFPO 1234", "FIQQ 1ZZ", "ASCN 1ZZ"} {
+		rec := &ImportRecord{postcode: postcode}
+		recVal.Validate(rec)
+
+		if rec.isValid {
+			error := fmt.Sprintf("Given postcode: %s, Expected: %t   got: %t", postcode, false, rec.isValid)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: false
+// validate postcodes that look like non-geographic postcodes but are not
+func Test_RecordValidator_Validate__NonGeographicLookalikes(t *testing.T) {
+
+	recVal := newNonGeographicRecordValidator()
+
+	for _, postcode := range []string{"BFPO 12345", "BFPO", "FIQQ 2ZZ", "XXQQ 1ZZ"} {
+		rec := &ImportRecord{postcode: postcode}
+		recVal.Validate(rec)
+
+		if rec.isValid {
+			error := fmt.Sprintf("Given postcode: %s, Expected: %t   got: %t", postcode, false, rec.isValid)
+			t.Error(error)
+		}
+	}
+}
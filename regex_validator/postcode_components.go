@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+)
+
+// names of the extra columns written to "succeeded_validation.csv" when components are requested, in the
+// order they are written
+var POSTCODE_COMPONENT_COLUMNS = []string{"area", "district", "sector", "unit", "outward_code", "inward_code"}
+
+// the reason given to a valid record whose postcode could not be split into components (a profile such as
+// permissive or one accepting BFPO numbers can accept postcodes without a 3 character inward code), the
+// components of the record are left empty
+const REASON_NO_COMPONENTS = "components_not_parsed"
+
+// type that stores the parsed parts of a valid postcode, using "SW1W 0NY" as an example:
+// area "SW", district "SW1W", sector "SW1W 0", unit "NY", outward code "SW1W" & inward code "0NY"
+type PostcodeComponents struct {
+	area     string
+	district string
+	sector   string
+	unit     string
+	outward  string
+	inward   string
+}
+
+// "parsePostcodeComponents" splits a postcode into its components, the postcode should already have been
+// found valid by a RegexValidatorGroup. The boolean returned is false if the postcode could not be split
+// (no whitespace between the outward & inward code or an inward code that is not 3 characters long)
+func parsePostcodeComponents(postcode string) (PostcodeComponents, bool) {
+	var comp PostcodeComponents
+
+	// the outward & inward code are separated by whitespace, the inward code is always 3 characters long
+	fields := strings.Fields(postcode)
+	if len(fields) != 2 || len(fields[1]) != 3 {
+		return comp, false
+	}
+
+	comp.outward = fields[0]
+	comp.inward = fields[1]
+
+	// the area is made up of the leading letters of the outward code, the rest of it is the district number
+	// (an outward code made only of letters such as "GIR" is its own area)
+	areaLen := strings.IndexFunc(comp.outward, func(r rune) bool { return r < 'A' || r > 'Z' })
+	if areaLen == -1 {
+		areaLen = len(comp.outward)
+	}
+	if areaLen == 0 {
+		return PostcodeComponents{}, false
+	}
+
+	comp.area = comp.outward[:areaLen]
+	comp.district = comp.outward
+	comp.sector = comp.outward + " " + comp.inward[:1]
+	comp.unit = comp.inward[1:]
+
+	return comp, true
+}
+
+// "columns" returns the components as a string slice in the same order as POSTCODE_COMPONENT_COLUMNS
+func (c PostcodeComponents) columns() []string {
+	return []string{c.area, c.district, c.sector, c.unit, c.outward, c.inward}
+}
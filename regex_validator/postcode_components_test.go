@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// expected: each postcode is split into the correct components
+// call parsePostcodeComponents with valid postcodes of each prefix structure
+func Test_parsePostcodeComponents__ValidPostcodes(t *testing.T) {
+
+	testCases := []struct {
+		postcode string
+		expected PostcodeComponents
+	}{
+		{"SW1W 0NY", PostcodeComponents{"SW", "SW1W", "SW1W 0", "NY", "SW1W", "0NY"}},
+		{"EC1A 1BB", PostcodeComponents{"EC", "EC1A", "EC1A 1", "BB", "EC1A", "1BB"}},
+		{"W1A 0AX", PostcodeComponents{"W", "W1A", "W1A 0", "AX", "W1A", "0AX"}},
+		{"M1 1AE", PostcodeComponents{"M", "M1", "M1 1", "AE", "M1", "1AE"}},
+		{"B33 8TH", PostcodeComponents{"B", "B33", "B33 8", "TH", "B33", "8TH"}},
+		{"DN55 1PT", PostcodeComponents{"DN", "DN55", "DN55 1", "PT", "DN55", "1PT"}},
+		{"GIR 0AA", PostcodeComponents{"GIR", "GIR", "GIR 0", "AA", "GIR", "0AA"}},
+	}
+
+	for _, element := range testCases {
+		result, ok := parsePostcodeComponents(element.postcode)
+
+		if !ok || result != element.expected {
+			error := fmt.Sprintf("Given postcode: %s, Expected: %v   got: %v (ok: %t)", element.postcode, element.expected, result, ok)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: false
+// call parsePostcodeComponents with postcodes that can not be split into components
+func Test_parsePostcodeComponents__InvalidPostcodes(t *testing.T) {
+
+	testPostcodes := []string{"LS44PL", "A1 9A", "11 1AA", "", "SW1W 0NY X"}
+
+	for _, element := range testPostcodes {
+		_, ok := parsePostcodeComponents(element)
+
+		if ok {
+			error := fmt.Sprintf("Given postcode: %s, Expected: %t   got: %t", element, false, ok)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: a valid record with empty components & the reason REASON_NO_COMPONENTS when its postcode can not be
+// split, components & no reason otherwise
+// validate postcodes the permissive & nongeographic profiles accept, some without a 3 character inward code
+func Test_RecordValidator__PostcodeWithoutComponents(t *testing.T) {
+
+	testCases := []struct {
+		profile  string
+		postcode string
+		expected PostcodeComponents
+		reason   string
+	}{
+		{PROFILE_PERMISSIVE, "LS44PL", PostcodeComponents{}, REASON_NO_COMPONENTS},
+		{PROFILE_PERMISSIVE, "ls4 4pl", PostcodeComponents{}, REASON_NO_COMPONENTS},
+		{PROFILE_PERMISSIVE, "LS4 4PL", PostcodeComponents{"LS", "LS4", "LS4 4", "PL", "LS4", "4PL"}, ""},
+		{PROFILE_BRIEF_2017_NON_GEOGRAPHIC, "BFPO 1234", PostcodeComponents{}, REASON_NO_COMPONENTS},
+		{PROFILE_BRIEF_2017_NON_GEOGRAPHIC, "ASCN 1ZZ", PostcodeComponents{"ASCN", "ASCN", "ASCN 1", "ZZ", "ASCN", "1ZZ"}, ""},
+	}
+
+	for _, element := range testCases {
+		validator, err := createRegexValidatorGroupForProfile(element.profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recVal := NewRecordValidator(validator, nil, nil)
+		recVal.SetRuleProfile(element.profile)
+
+		rec := &ImportRecord{postcode: element.postcode}
+		recVal.Validate(rec)
+
+		if !rec.isValid || rec.components != element.expected || rec.reason != element.reason {
+			error := fmt.Sprintf("Given profile: %s & postcode: %s, Expected: valid %v %q   got: %t %v %q", element.profile, element.postcode, element.expected, element.reason, rec.isValid, rec.components, rec.reason)
+			t.Error(error)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// type used to signal what a PostcodeDirectory knows about a postcode that has a valid format
+type DirectoryStatus uint8
+
+const (
+	DIRECTORY_NOT_CHECKED DirectoryStatus = iota // no directory was given so the postcode was not looked up
+	DIRECTORY_LIVE                               // the postcode is in the directory and is in use
+	DIRECTORY_UNKNOWN                            // the postcode has a valid format but is not in the directory
+	DIRECTORY_TERMINATED                         // the postcode is in the directory but is no longer in use
+)
+
+// names of the extra columns written to "succeeded_validation.csv" when a directory is used
+var DIRECTORY_STATUS_COLUMNS = []string{"directory_status", "terminated"}
+
+// "String" returns the name of the status as written to the output files
+func (s DirectoryStatus) String() string {
+	switch s {
+	case DIRECTORY_LIVE:
+		return "live"
+	case DIRECTORY_UNKNOWN:
+		return "valid_format_but_unknown"
+	case DIRECTORY_TERMINATED:
+		return "terminated"
+	}
+	return "not_checked"
+}
+
+// type that stores an index of every postcode in a postcode directory file (ONSPD / Code-Point Open style).
+// Each postcode is packed into a uint64 key (one byte per character, spaces removed) and maps to the date it
+// was terminated as written in the directory ("YYYYMM"), live postcodes map to an empty string
+type PostcodeDirectory struct {
+	index map[uint64]string
+}
+
+// names of the columns that may hold the postcode in a directory file, in order of preference
+var directoryPostcodeColumns = []string{"pcds", "pcd", "pcd2", "postcode"}
+
+// name of the column that holds the date of termination in an ONSPD file
+const DIRECTORY_TERMINATED_COLUMN = "doterm"
+
+// create and return a pointer to a new, empty PostcodeDirectory
+func NewPostcodeDirectory() *PostcodeDirectory {
+	return &PostcodeDirectory{index: make(map[uint64]string)}
+}
+
+// "loadPostcodeDirectory" opens the directory file at "path" and reads it into a new PostcodeDirectory
+func loadPostcodeDirectory(path string) (*PostcodeDirectory, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return readPostcodeDirectory(file)
+}
+
+// "readPostcodeDirectory" reads a directory from "r". If the first line has a column named "pcds", "pcd",
+// "pcd2" or "postcode" it is treated as a header (as in ONSPD files) and the "doterm" column is used for
+// the termination date if present. Otherwise the file is treated as having no header with the postcode in
+// the first column (as in Code-Point Open files)
+func readPostcodeDirectory(r io.Reader) (*PostcodeDirectory, error) {
+	dir := NewPostcodeDirectory()
+	reader := bufio.NewReader(r)
+
+	postcodeIdx, terminatedIdx := 0, -1
+	firstLine := true
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if len(strings.TrimSpace(line)) > 0 {
+			fields := splitDirectoryLine(line)
+
+			if firstLine {
+				if idx, termIdx, ok := findDirectoryColumns(fields); ok {
+					postcodeIdx, terminatedIdx = idx, termIdx
+					firstLine = false
+					continue
+				}
+			}
+			firstLine = false
+
+			if postcodeIdx >= len(fields) {
+				return nil, fmt.Errorf("postcode directory line has no column %d: %q", postcodeIdx, line)
+			}
+
+			terminated := ""
+			if terminatedIdx >= 0 && terminatedIdx < len(fields) {
+				terminated = fields[terminatedIdx]
+			}
+
+			key, ok := directoryKey(fields[postcodeIdx])
+			if !ok {
+				return nil, fmt.Errorf("postcode directory line has an invalid postcode: %q", line)
+			}
+			dir.index[key] = terminated
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if len(dir.index) == 0 {
+		return nil, errors.New("postcode directory contains no postcodes")
+	}
+
+	return dir, nil
+}
+
+// "Lookup" returns the DirectoryStatus of "postcode" and its termination date if it has been terminated
+func (d *PostcodeDirectory) Lookup(postcode string) (DirectoryStatus, string) {
+	key, ok := directoryKey(postcode)
+	if !ok {
+		return DIRECTORY_UNKNOWN, ""
+	}
+
+	terminated, found := d.index[key]
+	if !found {
+		return DIRECTORY_UNKNOWN, ""
+	}
+	if len(terminated) > 0 {
+		return DIRECTORY_TERMINATED, terminated
+	}
+	return DIRECTORY_LIVE, ""
+}
+
+// "Len" returns the number of postcodes in the directory
+func (d *PostcodeDirectory) Len() int {
+	return len(d.index)
+}
+
+// "directoryKey" packs a postcode into a uint64 with one byte per character, spaces are skipped and letters
+// are upper-cased so that "SW1W0NY", "sw1w 0ny" & "SW1W 0NY" all give the same key. The boolean returned
+// is false if the postcode is empty, longer than 8 characters or has characters that are not letters/digits
+func directoryKey(postcode string) (uint64, bool) {
+	var key uint64
+	length := 0
+
+	for i := 0; i < len(postcode); i++ {
+		c := postcode[i]
+		switch {
+		case c == ' ':
+			continue
+		case c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9'):
+		default:
+			return 0, false
+		}
+
+		length++
+		if length > 8 {
+			return 0, false
+		}
+		key = key<<8 | uint64(c)
+	}
+
+	return key, length > 0
+}
+
+// "splitDirectoryLine" splits a line of a directory file on commas, trimming space & double quotes
+func splitDirectoryLine(line string) []string {
+	fields := strings.Split(line, ",")
+	for i := range fields {
+		fields[i] = strings.Trim(strings.TrimSpace(fields[i]), `"`)
+	}
+	return fields
+}
+
+// "findDirectoryColumns" looks for the postcode & termination date columns in a header line
+func findDirectoryColumns(header []string) (postcodeIdx, terminatedIdx int, ok bool) {
+	postcodeIdx, terminatedIdx = -1, -1
+
+	for _, name := range directoryPostcodeColumns {
+		for i, field := range header {
+			if strings.EqualFold(field, name) {
+				postcodeIdx = i
+				break
+			}
+		}
+		if postcodeIdx >= 0 {
+			break
+		}
+	}
+
+	for i, field := range header {
+		if strings.EqualFold(field, DIRECTORY_TERMINATED_COLUMN) {
+			terminatedIdx = i
+		}
+	}
+
+	return postcodeIdx, terminatedIdx, postcodeIdx >= 0
+}
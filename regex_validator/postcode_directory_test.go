@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// expected: live, terminated & unknown postcodes are reported with the right status
+// read an ONSPD style directory (with a header & "doterm" column) and look up postcodes in it
+func Test_PostcodeDirectory__OnspdLookup(t *testing.T) {
+
+	onspd := "pcd,pcd2,pcds,dointr,doterm\n" +
+		"\"SW1W0NY\",\"SW1W 0NY\",\"SW1W 0NY\",\"198001\",\"\"\n" +
+		"\"M1  1AE\",\"M1   1AE\",\"M1 1AE\",\"198001\",\"200012\"\n"
+
+	dir, err := readPostcodeDirectory(strings.NewReader(onspd))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		postcode   string
+		status     DirectoryStatus
+		terminated string
+	}{
+		{"SW1W 0NY", DIRECTORY_LIVE, ""},
+		{"sw1w0ny", DIRECTORY_LIVE, ""},
+		{"M1 1AE", DIRECTORY_TERMINATED, "200012"},
+		{"ZZ99 9ZZ", DIRECTORY_UNKNOWN, ""},
+	}
+
+	for _, element := range testCases {
+		status, terminated := dir.Lookup(element.postcode)
+
+		if status != element.status || terminated != element.terminated {
+			error := fmt.Sprintf("Given postcode: %s, Expected: %s %q   got: %s %q", element.postcode, element.status, element.terminated, status, terminated)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: every postcode in the file is found & is live
+// read a Code-Point Open style directory (no header, postcode in the first column)
+func Test_PostcodeDirectory__CodePointLookup(t *testing.T) {
+
+	codePoint := "\"EC1A1BB\",10,530000,180000\n\"B33 8TH\",10,410000,286000"
+
+	dir, err := readPostcodeDirectory(strings.NewReader(codePoint))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dir.Len() != 2 {
+		t.Errorf("Expected: %d postcodes   got: %d", 2, dir.Len())
+	}
+
+	for _, postcode := range []string{"EC1A 1BB", "B33 8TH"} {
+		if status, _ := dir.Lookup(postcode); status != DIRECTORY_LIVE {
+			error := fmt.Sprintf("Given postcode: %s, Expected: %s   got: %s", postcode, DIRECTORY_LIVE, status)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: an error
+// read a directory that has no postcodes in it
+func Test_PostcodeDirectory__Empty(t *testing.T) {
+
+	_, err := readPostcodeDirectory(strings.NewReader("pcds,doterm\n"))
+
+	if err == nil {
+		t.Error("Given an empty directory, Expected: an error   got: nil")
+	}
+}
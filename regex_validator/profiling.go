@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// type that stores where the profiles of a run are written, an empty path turns that profile off
+type ProfilingOptions struct {
+	cpuProfilePath string
+	memProfilePath string
+	tracePath      string
+}
+
+// "startProfiling" starts the cpu profile & execution trace asked for in "opts" and returns a function that
+// stops them and writes the heap profile, it must be called once the run is complete. The files can be read
+// with "go tool pprof" & "go tool trace". The errors returned, by either function, are OutputErrors naming the file
+func startProfiling(opts ProfilingOptions) (stop func() error, err error) {
+	var cpuFile, traceFile *os.File
+
+	if len(opts.cpuProfilePath) > 0 {
+		if cpuFile, err = os.Create(opts.cpuProfilePath); err != nil {
+			return nil, &OutputError{path: opts.cpuProfilePath, err: err}
+		}
+		if err = pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, &OutputError{path: opts.cpuProfilePath, err: err}
+		}
+	}
+
+	if len(opts.tracePath) > 0 {
+		if traceFile, err = os.Create(opts.tracePath); err == nil {
+			err = trace.Start(traceFile)
+		}
+		if err != nil {
+			if cpuFile != nil {
+				pprof.StopCPUProfile()
+				cpuFile.Close()
+			}
+			if traceFile != nil {
+				traceFile.Close()
+			}
+			return nil, &OutputError{path: opts.tracePath, err: err}
+		}
+	}
+
+	stop = func() error {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				return &OutputError{path: opts.cpuProfilePath, err: err}
+			}
+		}
+
+		if traceFile != nil {
+			trace.Stop()
+			if err := traceFile.Close(); err != nil {
+				return &OutputError{path: opts.tracePath, err: err}
+			}
+		}
+
+		if len(opts.memProfilePath) > 0 {
+			memFile, err := os.Create(opts.memProfilePath)
+			if err != nil {
+				return &OutputError{path: opts.memProfilePath, err: err}
+			}
+			// run the garbage collector so the profile shows the memory still in use at the end of the run
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(memFile); err != nil {
+				memFile.Close()
+				return &OutputError{path: opts.memProfilePath, err: err}
+			}
+			if err := memFile.Close(); err != nil {
+				return &OutputError{path: opts.memProfilePath, err: err}
+			}
+		}
+		return nil
+	}
+
+	return stop, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// expected: a profile that can not be written is an OutputError, whether it is created when profiling starts or
+// when it stops
+func Test_startProfiling__UnwritablePath(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "no such directory", "profile")
+
+	_, err := startProfiling(ProfilingOptions{cpuProfilePath: path})
+	if _, ok := err.(*OutputError); !ok || exitCodeForError(err) != EXIT_IO_FAILURE {
+		error := fmt.Sprintf("Given -cpuprofile: %s, Expected: an OutputError   got: %v", path, err)
+		t.Error(error)
+	}
+
+	stop, err := startProfiling(ProfilingOptions{memProfilePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); exitCodeForError(err) != EXIT_IO_FAILURE {
+		error := fmt.Sprintf("Given -memprofile: %s, Expected: an OutputError   got: %v", path, err)
+		t.Error(error)
+	}
+}
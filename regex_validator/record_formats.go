@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// names of the formats input files can be read in & output files written in
+const (
+	FORMAT_CSV   = "csv"   // comma separated, the first line holds the column names
+	FORMAT_TSV   = "tsv"   // tab separated, the first line holds the column names
+	FORMAT_JSONL = "jsonl" // one JSON object per line (JSON Lines / NDJSON), there is no header
+
+	JSON_ID_FIELD_DEFAULT       = "row_id"
+	JSON_POSTCODE_FIELD_DEFAULT = "postcode"
+)
+
+// type that stores the names of the JSON fields holding the row id & postcode, used to read & write JSON lines
+type JsonFieldNames struct {
+	id       string
+	postcode string
+}
+
+// type that stores how the lines of an input file are read: the extension its files have, whether the first line
+// holds the column names (the column names to use if it does not) & how a line is split into its row id & postcode
+type InputFormat struct {
+	extension   string
+	hasHeader   bool
+	headerError string
+	columnNames []string
+	split       func(line string) ([]string, error)
+	encoding    string // the character encoding of files without a byte order mark, UTF-8 if it is empty
+
+	blankLinesMalformed bool // whether a blank line is a malformed row rather than being skipped
+	skipMalformed       bool // whether a malformed row is skipped & counted rather than stopping the run
+}
+
+// type that stores how records are written to an output file: the extension its files have, what is written
+// before the first record & how each record is written, "values" holds the value of each of "columns"
+type OutputFormat struct {
+	extension   string
+	writeHeader func(w io.Writer, columns []string) error
+	writeRecord func(w io.Writer, columns []string, values []interface{}) error
+}
+
+// maps the name of each input format to the function that creates it
+var inputFormats = map[string]func(fields JsonFieldNames) *InputFormat{
+	FORMAT_CSV: func(fields JsonFieldNames) *InputFormat { return newDelimitedInputFormat(".csv", ",", "comma") },
+	FORMAT_TSV: func(fields JsonFieldNames) *InputFormat { return newDelimitedInputFormat(".tsv", "\t", "tab") },
+	FORMAT_JSONL: func(fields JsonFieldNames) *InputFormat {
+		return &InputFormat{
+			extension:   ".jsonl",
+			columnNames: []string{fields.id, fields.postcode},
+			split:       func(line string) ([]string, error) { return splitJsonLine(line, fields) },
+		}
+	},
+}
+
+// maps the name of each output format to the function that creates it
+var outputFormats = map[string]func(fields JsonFieldNames) *OutputFormat{
+	FORMAT_CSV: func(fields JsonFieldNames) *OutputFormat {
+		return &OutputFormat{extension: ".csv", writeHeader: writeCsvHeader, writeRecord: writeCsvRecord}
+	},
+	FORMAT_JSONL: func(fields JsonFieldNames) *OutputFormat {
+		return &OutputFormat{
+			extension:   ".jsonl",
+			writeHeader: func(w io.Writer, columns []string) error { return nil },
+			writeRecord: func(w io.Writer, columns []string, values []interface{}) error {
+				// the row id & postcode are always written with the configured field names
+				names := append([]string{fields.id, fields.postcode}, columns[FIELDS_PER_RECORD:]...)
+				return writeJsonRecord(w, names, values)
+			},
+		}
+	},
+}
+
+// "inputFormatNames" returns the names of every input format in alphabetical order
+func inputFormatNames() []string {
+	names := make([]string, 0, len(inputFormats))
+	for name := range inputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// "outputFormatNames" returns the names of every output format in alphabetical order
+func outputFormatNames() []string {
+	names := make([]string, 0, len(outputFormats))
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// "newInputFormat" creates the input format named "name", an error is returned if there is no format with that name
+func newInputFormat(name string, fields JsonFieldNames) (*InputFormat, error) {
+	create, ok := inputFormats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown input format \"%s\", must be one of: %s", name, strings.Join(inputFormatNames(), ", "))
+	}
+	return create(fields), nil
+}
+
+// "newOutputFormat" creates the output format named "name", an error is returned if there is no format with that name
+func newOutputFormat(name string, fields JsonFieldNames) (*OutputFormat, error) {
+	create, ok := outputFormats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format \"%s\", must be one of: %s", name, strings.Join(outputFormatNames(), ", "))
+	}
+	return create(fields), nil
+}
+
+// "withExtension" returns the file name "name" with its extension replaced by "extension"
+func withExtension(name, extension string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + extension
+}
+
+// "newDelimitedInputFormat" creates an input format for files with a header line & the row id & postcode in the
+// first two fields of each line, separated by "separator"
+func newDelimitedInputFormat(extension, separator, separatorName string) *InputFormat {
+	return &InputFormat{
+		extension:   extension,
+		hasHeader:   true,
+		headerError: fmt.Sprintf("the first line of the %s file must hold the column names, separated by a %s", extension, separatorName),
+		split: func(line string) ([]string, error) {
+			record, ok := splitDelimitedLine(line, separator)
+			if !ok {
+				return nil, fmt.Errorf("no %s between the row id & postcode", separatorName)
+			}
+			return record, nil
+		},
+	}
+}
+
+// "splitDelimitedLine" splits "line" on "separator" & returns the first two fields trimmed of space, or false if
+// there is no separator. A field may be quoted as in RFC 4180 (`"AB1 2CD"`, with `""` for a quote inside it), its
+// quotes are removed & a separator inside them does not end the field
+func splitDelimitedLine(line, separator string) ([]string, bool) {
+	if !strings.Contains(line, `"`) {
+		res := strings.Split(line, separator)
+		if len(res) < FIELDS_PER_RECORD {
+			return nil, false
+		}
+		return []string{strings.TrimSpace(res[0]), strings.TrimSpace(res[1])}, true
+	}
+
+	first, rest, more := nextDelimitedField(line, separator)
+	if !more {
+		return nil, false
+	}
+	second, _, _ := nextDelimitedField(rest, separator)
+	return []string{first, second}, true
+}
+
+// "nextDelimitedField" returns the first field of "line" trimmed of space, the text after its separator & whether
+// there is a separator. A field that is not quoted properly (text after the closing quote, or no closing quote) is
+// read as it is, quotes & all, so its value is simply not a valid row id or postcode
+func nextDelimitedField(line, separator string) (string, string, bool) {
+	if quoted := strings.TrimLeft(line, " "); strings.HasPrefix(quoted, `"`) {
+		var field strings.Builder
+		for i := 1; i < len(quoted); i++ {
+			if quoted[i] != '"' {
+				field.WriteByte(quoted[i])
+				continue
+			}
+			if i+1 < len(quoted) && quoted[i+1] == '"' {
+				field.WriteByte('"')
+				i++
+				continue
+			}
+
+			after := strings.TrimLeft(quoted[i+1:], " ")
+			if strings.HasPrefix(after, separator) {
+				return strings.TrimSpace(field.String()), after[len(separator):], true
+			}
+			if len(strings.TrimSpace(after)) == 0 {
+				return strings.TrimSpace(field.String()), "", false
+			}
+			break
+		}
+	}
+
+	end := strings.Index(line, separator)
+	if end < 0 {
+		return strings.TrimSpace(line), "", false
+	}
+	return strings.TrimSpace(line[:end]), line[end+len(separator):], true
+}
+
+// "splitJsonLine" reads "line" as a JSON object & returns the values of its row id & postcode fields trimmed of
+// space. The row id may be a number or a string, the postcode must be a string
+func splitJsonLine(line string, fields JsonFieldNames) ([]string, error) {
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil || object == nil {
+		return nil, fmt.Errorf("the line is not a JSON object")
+	}
+
+	var rowId string
+	switch value := object[fields.id].(type) {
+	case json.Number:
+		rowId = value.String()
+	case string:
+		rowId = value
+	case nil:
+		return nil, fmt.Errorf("no \"%s\" field", fields.id)
+	default:
+		return nil, fmt.Errorf("the \"%s\" field must be a number or a string", fields.id)
+	}
+
+	postcode, ok := object[fields.postcode].(string)
+	if !ok {
+		return nil, fmt.Errorf("no \"%s\" field holding a string", fields.postcode)
+	}
+	return []string{strings.TrimSpace(rowId), strings.TrimSpace(postcode)}, nil
+}
+
+// "writeCsvHeader" writes the column names separated by commas
+func writeCsvHeader(w io.Writer, columns []string) error {
+	_, err := fmt.Fprintln(w, strings.Join(columns, ","))
+	return err
+}
+
+// "writeCsvRecord" writes the values separated by commas, numbers with a fraction are written to 2 decimal
+// places & text that holds a comma, quote or line break is quoted
+func writeCsvRecord(w io.Writer, columns []string, values []interface{}) error {
+	fields := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case string:
+			fields[i] = csvField(v)
+		case float64:
+			fields[i] = strconv.FormatFloat(v, 'f', 2, 64)
+		default:
+			fields[i] = fmt.Sprint(v)
+		}
+	}
+	_, err := fmt.Fprintln(w, strings.Join(fields, ","))
+	return err
+}
+
+// "writeJsonRecord" writes the values as one JSON object on its own line, with the fields in the order of
+// "columns". Numbers with a fraction are rounded to 2 decimal places to match the .csv output
+func writeJsonRecord(w io.Writer, columns []string, values []interface{}) error {
+	var line bytes.Buffer
+	line.WriteByte('{')
+	for i, value := range values {
+		if v, ok := value.(float64); ok {
+			value = math.Round(v*100) / 100
+		}
+		name, err := json.Marshal(columns[i])
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		line.Write(name)
+		line.WriteByte(':')
+		line.Write(data)
+	}
+	line.WriteString("}\n")
+
+	_, err := w.Write(line.Bytes())
+	return err
+}
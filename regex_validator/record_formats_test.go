@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// expected: the row id (a number or a string) & postcode are read from the named fields, anything else is an error
+func Test_splitJsonLine(t *testing.T) {
+	fields := JsonFieldNames{id: "id", postcode: "pc"}
+
+	testCases := []struct {
+		line     string
+		expected []string
+	}{
+		{`{"id": 12, "pc": "EC1A 1BB"}` + "\n", []string{"12", "EC1A 1BB"}},
+		{`{"pc": " m1 1ae ", "other": [1, 2], "id": "7"}`, []string{"7", "m1 1ae"}},
+		{`{"id": 12345678901234567890, "pc": ""}`, []string{"12345678901234567890", ""}},
+		{`{"id": 12}`, nil},
+		{`{"id": 12, "pc": 5}`, nil},
+		{`{"id": true, "pc": "EC1A 1BB"}`, nil},
+		{`{"row_id": 12, "postcode": "EC1A 1BB"}`, nil},
+		{`[12, "EC1A 1BB"]`, nil},
+		{`12,EC1A 1BB`, nil},
+		{"", nil},
+	}
+
+	for _, element := range testCases {
+		result, err := splitJsonLine(element.line, fields)
+
+		if !reflect.DeepEqual(result, element.expected) || (err == nil) != (element.expected != nil) {
+			error := fmt.Sprintf("Given line: %q, Expected: %q   got: %q (error: %v)", element.line, element.expected, result, err)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: tab separated lines are split on the tab, a line without one is an error
+func Test_InputFormat__Tsv(t *testing.T) {
+	format, err := newInputFormat(FORMAT_TSV, JsonFieldNames{})
+	check(err)
+
+	if result, err := format.split("12\t EC1A 1BB\tignored\n"); err != nil || !reflect.DeepEqual(result, []string{"12", "EC1A 1BB"}) {
+		error := fmt.Sprintf("Given a tab separated line, Expected: [12 EC1A 1BB]   got: %q (error: %v)", result, err)
+		t.Error(error)
+	}
+	if _, err := format.split("12,EC1A 1BB\n"); err == nil {
+		t.Error("Given a comma separated line, Expected: an error   got: nil")
+	}
+	if _, err := newInputFormat("xml", JsonFieldNames{}); err == nil {
+		t.Error("Given input format: xml, Expected: an error   got: nil")
+	}
+}
+
+// expected: each output format writes the same values, .csv quoting text that needs it & JSON lines using the
+// configured field names for the row id & postcode
+func Test_OutputFormat__WriteRecord(t *testing.T) {
+	columns := []string{"id", "pc", "suggestion", "confidence", "source_file"}
+	values := []interface{}{uint64(7), "LS44PL", "LS4 4PL", 0.8333, "a,b.csv"}
+
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{FORMAT_CSV, "id,pc,suggestion,confidence,source_file\n7,LS44PL,LS4 4PL,0.83,\"a,b.csv\"\n"},
+		{FORMAT_JSONL, `{"row":7,"postcode":"LS44PL","suggestion":"LS4 4PL","confidence":0.83,"source_file":"a,b.csv"}` + "\n"},
+	}
+
+	for _, element := range testCases {
+		format, err := newOutputFormat(element.name, JsonFieldNames{id: "row", postcode: "postcode"})
+		check(err)
+
+		var buffer bytes.Buffer
+		check(format.writeHeader(&buffer, columns))
+		check(format.writeRecord(&buffer, columns, values))
+
+		if result := buffer.String(); result != element.expected {
+			error := fmt.Sprintf("Given output format: %s, Expected: %q   got: %q", element.name, element.expected, result)
+			t.Error(error)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// names of the record sinks that come with the program, the valid & invalid records can each be sent to any of them
+const (
+	SINK_CSV        = FORMAT_CSV
+	SINK_JSONL      = FORMAT_JSONL
+	SINK_CSV_GZIP   = FORMAT_CSV + ".gz"
+	SINK_JSONL_GZIP = FORMAT_JSONL + ".gz"
+	SINK_DISCARD    = "discard"
+)
+
+// RecordSink is where one stream of output records (the valid or the invalid records) is written. "Open" is called
+// once with the names of the columns before any record is written, "Write" once for each record with the value of
+// each column & "Close" once at the end, even if an earlier call failed
+type RecordSink interface {
+	Open(columns []string) error
+	Write(values []interface{}) error
+	Close() error
+}
+
+// type of function that creates a RecordSink for the output file "name" (without its extension, e.g.
+// "succeeded_validation") in the output directory
+type RecordSinkFactory func(name string, opts *ProgramOptions) RecordSink
+
+// maps the name of each record sink to the function that creates it, other sinks (e.g. a database loader) can be
+// added to it from an init function in their own file & are then selected with -succeeded-sink & -failed-sink
+var recordSinks = map[string]RecordSinkFactory{
+	SINK_CSV: func(name string, opts *ProgramOptions) RecordSink {
+		return NewCsvSink(opts.outputPath(name + ".csv"))
+	},
+	SINK_JSONL: func(name string, opts *ProgramOptions) RecordSink {
+		return NewJsonlSink(opts.outputPath(name+".jsonl"), opts.jsonFields)
+	},
+	SINK_CSV_GZIP: func(name string, opts *ProgramOptions) RecordSink {
+		return NewGzipSink(NewCsvSink(opts.outputPath(name + ".csv")))
+	},
+	SINK_JSONL_GZIP: func(name string, opts *ProgramOptions) RecordSink {
+		return NewGzipSink(NewJsonlSink(opts.outputPath(name+".jsonl"), opts.jsonFields))
+	},
+	SINK_DISCARD: func(name string, opts *ProgramOptions) RecordSink {
+		return NewDiscardSink()
+	},
+}
+
+// "recordSinkNames" returns the names of every record sink in alphabetical order
+func recordSinkNames() []string {
+	names := make([]string, 0, len(recordSinks))
+	for name := range recordSinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// "newRecordSink" creates the record sink named "sinkName" for the output file "name", an error is returned if
+// there is no sink with that name
+func newRecordSink(sinkName, name string, opts *ProgramOptions) (RecordSink, error) {
+	create, ok := recordSinks[sinkName]
+	if !ok {
+		return nil, fmt.Errorf("unknown record sink \"%s\", must be one of: %s", sinkName, strings.Join(recordSinkNames(), ", "))
+	}
+	return create(name, opts), nil
+}
+
+// type of RecordSink that writes records to the file "path" in an OutputFormat, through "compress" if it is set.
+// The number of bytes written before ("text") & after ("compressed") compression is counted & the bytes of the
+// file are added to "checksum" if it is set
+type FileSink struct {
+	path       string
+	format     *OutputFormat
+	compress   func(w io.Writer) io.WriteCloser
+	checksum   hash.Hash
+	file       *os.File
+	inner      io.WriteCloser
+	text       countingWriter
+	compressed countingWriter
+	writer     *bufio.Writer
+	columns    []string
+	err        error
+}
+
+// "NewFileSink" creates a FileSink that writes records to "path" in "format"
+func NewFileSink(path string, format *OutputFormat) *FileSink {
+	return &FileSink{path: path, format: format}
+}
+
+// "NewCsvSink" creates a FileSink that writes records to the .csv file "path"
+func NewCsvSink(path string) *FileSink {
+	return NewFileSink(path, outputFormats[FORMAT_CSV](JsonFieldNames{}))
+}
+
+// "NewJsonlSink" creates a FileSink that writes records to "path" as JSON lines, the row id & postcode are
+// written with the field names "fields"
+func NewJsonlSink(path string, fields JsonFieldNames) *FileSink {
+	return NewFileSink(path, outputFormats[FORMAT_JSONL](fields))
+}
+
+// "NewGzipSink" makes "sink" gzip what it writes (compressing on every CPU), ".gz" is added to its path
+func NewGzipSink(sink *FileSink) *FileSink {
+	sink.path += ".gz"
+	sink.compress = func(w io.Writer) io.WriteCloser { return NewParallelGzipWriter(w) }
+	return sink
+}
+
+// "Open" creates the file & writes the column names (if the format has a header)
+func (sink *FileSink) Open(columns []string) error {
+	file, err := os.Create(sink.path)
+	if err != nil {
+		return sink.fail(err)
+	}
+	sink.file = file
+	sink.columns = columns
+
+	sink.compressed = countingWriter{w: file}
+	if sink.checksum != nil {
+		sink.compressed.w = io.MultiWriter(file, sink.checksum)
+	}
+	var out io.Writer = &sink.compressed
+	if sink.compress != nil {
+		sink.inner = sink.compress(out)
+		out = sink.inner
+	}
+	sink.text = countingWriter{w: out}
+	sink.writer = bufio.NewWriter(&sink.text)
+
+	return sink.fail(sink.format.writeHeader(sink.writer, columns))
+}
+
+// "Write" writes one record, nothing more is written after an error
+func (sink *FileSink) Write(values []interface{}) error {
+	if sink.err != nil {
+		return sink.err
+	}
+	return sink.fail(sink.format.writeRecord(sink.writer, sink.columns, values))
+}
+
+// "writeEncoded" writes a record already written in the sink's format by "format.writeRecord"
+func (sink *FileSink) writeEncoded(record []byte) error {
+	if sink.err != nil {
+		return sink.err
+	}
+	_, err := sink.writer.Write(record)
+	return sink.fail(err)
+}
+
+// "Close" flushes what is left to write & closes the file
+func (sink *FileSink) Close() error {
+	if sink.file == nil {
+		return sink.err
+	}
+	if sink.err == nil {
+		sink.fail(sink.writer.Flush())
+	}
+	if sink.inner != nil {
+		sink.fail(sink.inner.Close())
+	}
+	sink.fail(sink.file.Close())
+	sink.file = nil
+	return sink.err
+}
+
+// "sizes" returns the number of bytes of text written to the file & the size of the file, they differ when the
+// sink compresses what it writes. It is only complete once the sink is closed
+func (sink *FileSink) sizes() (text, compressed int64) {
+	return sink.text.n, sink.compressed.n
+}
+
+// "textSize" returns the number of bytes of text written so far, including those not yet flushed
+func (sink *FileSink) textSize() int64 {
+	return sink.text.n + int64(sink.writer.Buffered())
+}
+
+// "fail" keeps the first error the sink has as an OutputError & returns it
+func (sink *FileSink) fail(err error) error {
+	if err != nil && sink.err == nil {
+		sink.err = &OutputError{path: sink.path, err: err}
+	}
+	return sink.err
+}
+
+// type of RecordSink that only counts the records it is sent, for runs where one of the streams is not needed
+type DiscardSink struct {
+	numRecords int
+}
+
+// "NewDiscardSink" creates a DiscardSink
+func NewDiscardSink() *DiscardSink {
+	return &DiscardSink{}
+}
+
+func (sink *DiscardSink) Open(columns []string) error      { return nil }
+func (sink *DiscardSink) Write(values []interface{}) error { sink.numRecords++; return nil }
+func (sink *DiscardSink) Close() error                     { return nil }
+
+// "writeRecords" writes each of "recs" to "sink", "values" gives the value of each of "columns" for a record.
+// The sink is always closed, the first error is returned
+func writeRecords(sink RecordSink, columns []string, recs []*ImportRecord, values func(rec *ImportRecord) []interface{}) error {
+	err := sink.Open(columns)
+	for i := 0; err == nil && i < len(recs); i++ {
+		err = sink.Write(values(recs[i]))
+	}
+	if e := sink.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// "newOutputSinks" creates the sinks the valid & invalid records are written to, those named with -succeeded-sink
+// & -failed-sink or a file in the -output-format when they are not given, gzipped if -compress gzip is given &
+// split into chunks if -max-rows-per-file or -max-bytes-per-file is given
+func newOutputSinks(opts *ProgramOptions) (valid, invalid RecordSink, err error) {
+	if _, err := newOutputFormat(opts.outputFormat, opts.jsonFields); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkCompression(opts.compress); err != nil {
+		return nil, nil, err
+	}
+
+	// with -compress the files written in the output format are compressed, other sinks are left as they are
+	sinkName := func(name string) string {
+		if len(name) == 0 {
+			name = opts.outputFormat
+		}
+		if opts.compress == COMPRESS_GZIP && (name == SINK_CSV || name == SINK_JSONL) {
+			name += ".gz"
+		}
+		return name
+	}
+
+	// with -max-rows-per-file or -max-bytes-per-file the sinks that write a file write numbered chunk files instead
+	chunked := func(sinkName, name string, sink RecordSink) RecordSink {
+		if _, ok := sink.(*FileSink); !ok || (opts.maxRowsPerFile == 0 && opts.maxBytesPerFile == 0) {
+			return sink
+		}
+		create := recordSinks[sinkName]
+		return NewChunkedSink(func(index int) *FileSink { return create(chunkedSinkName(name, index), opts).(*FileSink) },
+			opts.maxRowsPerFile, opts.maxBytesPerFile, opts.outputPath(name+CHUNK_MANIFEST_EXTENSION))
+	}
+
+	validName, invalidName := withExtension(SUCCEEDED_FILE_NAME, ""), withExtension(FAILED_FILE_NAME, "")
+	valid, err = newRecordSink(sinkName(opts.succeededSink), validName, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	invalid, err = newRecordSink(sinkName(opts.failedSink), invalidName, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chunked(sinkName(opts.succeededSink), validName, valid), chunked(sinkName(opts.failedSink), invalidName, invalid), nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// type of RecordSink that keeps what it is sent, used to test sinks added from outside the program
+type memorySink struct {
+	columns []string
+	records [][]interface{}
+	closed  bool
+}
+
+func (sink *memorySink) Open(columns []string) error { sink.columns = columns; return nil }
+func (sink *memorySink) Write(values []interface{}) error {
+	sink.records = append(sink.records, values)
+	return nil
+}
+func (sink *memorySink) Close() error { sink.closed = true; return nil }
+
+// expected: a gzip sink writes the same text as the sink it wraps, compressed, to its path with ".gz" added
+func Test_NewGzipSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sinks")
+	check(err)
+	defer os.RemoveAll(dir)
+
+	sink := NewGzipSink(NewCsvSink(filepath.Join(dir, "out.csv")))
+	recs := []*ImportRecord{{rowId: 1, postcode: "EC1A 1BB"}, {rowId: 2, postcode: "M1 1AE"}}
+	check(writeRecords(sink, []string{"row_id", "postcode"}, recs, func(rec *ImportRecord) []interface{} {
+		return []interface{}{rec.rowId, rec.postcode}
+	}))
+
+	file, err := os.Open(filepath.Join(dir, "out.csv.gz"))
+	check(err)
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	check(err)
+	result, err := ioutil.ReadAll(reader)
+
+	expected := "row_id,postcode\n1,EC1A 1BB\n2,M1 1AE\n"
+	if err != nil || string(result) != expected {
+		error := fmt.Sprintf("Expected: %q   got: %q (error: %v)", expected, result, err)
+		t.Error(error)
+	}
+}
+
+// expected: a file sink that can not create its file returns an OutputError & can still be closed
+func Test_FileSink__OpenFails(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "no-such-directory", "out.csv")
+	sink := NewCsvSink(path)
+
+	err := writeRecords(sink, []string{"row_id", "postcode"}, nil, nil)
+	if outputErr, ok := err.(*OutputError); !ok || outputErr.path != path {
+		error := fmt.Sprintf("Given path: %s, Expected: an OutputError for the path   got: %v", path, err)
+		t.Error(error)
+	}
+}
+
+// expected: each stream goes to the sink named for it, a sink added to "recordSinks" can be selected by name &
+// nothing is written for a discarded stream
+func Test_runImport__RecordSinks(t *testing.T) {
+	path, dir := writeTempInput(t, "row_id,postcode\n2,LS44PL\n1,EC1A 1BB\n")
+	defer os.RemoveAll(dir)
+
+	memory := &memorySink{}
+	recordSinks["memory"] = func(name string, opts *ProgramOptions) RecordSink { return memory }
+	defer delete(recordSinks, "memory")
+
+	opts := newDefaultProgramOptions(path, dir)
+	opts.succeededSink = "memory"
+	opts.failedSink = SINK_DISCARD
+	if _, err := runImport(time.Now(), opts); err != nil {
+		error := fmt.Sprintf("Expected: no error   got: %v", err)
+		t.Fatal(error)
+	}
+
+	expected := [][]interface{}{{uint64(1), "EC1A 1BB"}}
+	if !memory.closed || !reflect.DeepEqual(memory.columns, []string{"row_id", "postcode"}) || !reflect.DeepEqual(memory.records, expected) {
+		error := fmt.Sprintf("Expected: the valid record in the memory sink   got: %+v", memory)
+		t.Error(error)
+	}
+	if files := listFiles(t, dir); !reflect.DeepEqual(files, []string{"input.csv"}) {
+		error := fmt.Sprintf("Expected: no output files   got: %v", files)
+		t.Error(error)
+	}
+
+	opts.failedSink = "nowhere"
+	if _, err := runImport(time.Now(), opts); err == nil {
+		t.Error("Given -failed-sink nowhere, Expected: an error   got: nil")
+	}
+}
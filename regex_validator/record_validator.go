@@ -0,0 +1,71 @@
+package main
+
+// type that runs every stage of validation on an ImportRecord. The RegexValidatorGroup decides if a record
+// is valid unless it is accepted by one of the PostcodeClassRules first, the optional stages (directory
+// lookup, suggestions) only add information to the record
+type RecordValidator struct {
+	validator  *RegexValidatorGroup
+	classRules []*PostcodeClassRule
+	directory  *PostcodeDirectory
+	suggester  *SuggestionEngine
+	profile    string
+}
+
+// create and return a pointer to a new RecordValidator, "dir" & "sug" may be nil to skip those stages
+func NewRecordValidator(val *RegexValidatorGroup, dir *PostcodeDirectory, sug *SuggestionEngine) *RecordValidator {
+	return &RecordValidator{validator: val, directory: dir, suggester: sug}
+}
+
+// add a rule that accepts postcodes of a class other than CLASS_GEOGRAPHIC
+func (r *RecordValidator) AddClassRule(rule *PostcodeClassRule) {
+	r.classRules = append(r.classRules, rule)
+}
+
+// "SetRuleProfile" adds the class rules of the rule profile named "name" (the one the RegexValidatorGroup was
+// created for) & gives invalid records the failure reasons of that profile, brief2017's are given until it is set
+func (r *RecordValidator) SetRuleProfile(name string) {
+	r.profile = name
+	for _, rule := range createClassRulesForProfile(name) {
+		r.AddClassRule(rule)
+	}
+}
+
+// "Validate" sets the validity & class of "rec", valid records have their postcode split into components and
+// are looked up in the directory, invalid records are given a failure reason & a suggested correction. A valid
+// record whose postcode cannot be split is given the reason REASON_NO_COMPONENTS & empty components
+func (r *RecordValidator) Validate(rec *ImportRecord) {
+	rec.isValid = false
+	rec.class = CLASS_GEOGRAPHIC
+
+	// the class rules are checked first as some non-geographic postcodes (BF1) also have a geographic shape
+	for _, rule := range r.classRules {
+		if rule.validator.GroupIsStringValid(rec.postcode) {
+			rec.isValid = true
+			rec.class = rule.class
+			break
+		}
+	}
+
+	if !rec.isValid {
+		rec.isValid = r.validator.GroupIsStringValid(rec.postcode)
+	}
+
+	if rec.isValid {
+		components, ok := parsePostcodeComponents(rec.postcode)
+		if ok {
+			rec.components = components
+		} else {
+			rec.components = PostcodeComponents{}
+			rec.reason = REASON_NO_COMPONENTS
+		}
+		if r.directory != nil {
+			rec.directoryStatus, rec.terminated = r.directory.Lookup(rec.postcode)
+		}
+		return
+	}
+
+	rec.reason = failureReason(rec.postcode, r.profile)
+	if r.suggester != nil {
+		rec.suggestion, rec.confidence, _ = r.suggester.Suggest(rec.postcode)
+	}
+}
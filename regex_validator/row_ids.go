@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+// the name of the file row id gaps are written to
+const ROW_ID_GAPS_FILE_NAME = "row_id_gaps.csv"
+
+// type that stores the smallest & largest row id a record may have, both are inclusive
+type RowIdRange struct {
+	min uint64
+	max uint64
+}
+
+// the range used when no other is given, any id that is not negative and fits in an int64 is accepted
+var ROW_ID_RANGE_DEFAULT = RowIdRange{min: 0, max: math.MaxInt64}
+
+// "parseRowId" converts the string "str" into a row id, an error is returned if it is not a whole number,
+// is negative or is outside of the range "rng"
+func parseRowId(str string, rng RowIdRange) (uint64, error) {
+	id, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("row id \"%s\" is not a whole number that fits in 64 bits", str)
+	}
+	if id < 0 {
+		return 0, fmt.Errorf("row id %d is negative", id)
+	}
+	if uint64(id) < rng.min || uint64(id) > rng.max {
+		return 0, fmt.Errorf("row id %d is outside of the range %d to %d", id, rng.min, rng.max)
+	}
+	return uint64(id), nil
+}
+
+// type that stores a range of row ids that were not found in the input file, both ends are inclusive
+type RowIdGap struct {
+	first uint64
+	last  uint64
+}
+
+// "findRowIdGaps" returns each range of row ids missing between the smallest and largest row id found.
+// Both groups must already be sorted, they are merged so gaps are only reported if missing from both
+func findRowIdGaps(validRecs, invalidRecs ImportRecordGroup) []RowIdGap {
+	var gaps []RowIdGap
+	var prev uint64
+	started := false
+
+	i, j := 0, 0
+	for i < len(validRecs) || j < len(invalidRecs) {
+		// take the smaller of the next record of each group
+		var id uint64
+		if j >= len(invalidRecs) || (i < len(validRecs) && validRecs[i].rowId <= invalidRecs[j].rowId) {
+			id = validRecs[i].rowId
+			i++
+		} else {
+			id = invalidRecs[j].rowId
+			j++
+		}
+
+		if started && id > prev+1 {
+			gaps = append(gaps, RowIdGap{first: prev + 1, last: id - 1})
+		}
+		prev = id
+		started = true
+	}
+
+	return gaps
+}
+
+// "writeRowIdGapsFile" writes each RowIdGap to a csv file at "path", one gap per row
+func writeRowIdGapsFile(path string, gaps []RowIdGap) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	fmt.Fprintln(writer, "first_missing,last_missing,count")
+
+	for _, gap := range gaps {
+		fmt.Fprintf(writer, "%d,%d,%d\n", gap.first, gap.last, gap.last-gap.first+1)
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
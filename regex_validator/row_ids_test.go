@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// expected: each row id is parsed or rejected as in the table
+// call parseRowId with ids inside & outside of a range
+func Test_parseRowId(t *testing.T) {
+
+	rng := RowIdRange{min: 10, max: 5000000000}
+
+	testCases := []struct {
+		str      string
+		expected uint64
+		isError  bool
+	}{
+		{"10", 10, false},
+		{"2147483648", 2147483648, false},
+		{"5000000000", 5000000000, false},
+		{"5000000001", 0, true},
+		{"9", 0, true},
+		{"-1", 0, true},
+		{"abc", 0, true},
+		{"", 0, true},
+	}
+
+	for _, element := range testCases {
+		result, err := parseRowId(element.str, rng)
+
+		if result != element.expected || (err != nil) != element.isError {
+			error := fmt.Sprintf("Given row id: %q, Expected: %d (error: %t)   got: %d (error: %v)", element.str, element.expected, element.isError, result, err)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: an error
+// NewImportRecordInRange with a negative row id, which would wrap around if cast straight to an unsigned type
+func Test_NewImportRecordInRange__NegativeRowId(t *testing.T) {
+	if _, err := NewImportRecordInRange([]string{"-5", "M1 1AE"}, ROW_ID_RANGE_DEFAULT); err == nil {
+		t.Error("Given row id: -5, Expected: an error   got: nil")
+	}
+}
+
+// expected: the ranges missing from both groups combined
+func Test_findRowIdGaps(t *testing.T) {
+
+	valid := ImportRecordGroup{newTestRecord(1, "", 2), newTestRecord(2, "", 3), newTestRecord(9, "", 4), newTestRecord(20, "", 8)}
+	invalid := ImportRecordGroup{newTestRecord(5, "", 5), newTestRecord(9, "", 6), newTestRecord(12, "", 7)}
+	sort.Sort(valid)
+	sort.Sort(invalid)
+
+	expected := []RowIdGap{{3, 4}, {6, 8}, {10, 11}, {13, 19}}
+	result := findRowIdGaps(valid, invalid)
+
+	if !reflect.DeepEqual(result, expected) {
+		error := fmt.Sprintf("Expected: %v   got: %v", expected, result)
+		t.Error(error)
+	}
+}
+
+// expected: no gaps
+func Test_findRowIdGaps__NoGaps(t *testing.T) {
+
+	valid := ImportRecordGroup{newTestRecord(1, "", 2), newTestRecord(3, "", 4)}
+	invalid := ImportRecordGroup{newTestRecord(2, "", 3)}
+
+	if result := findRowIdGaps(valid, invalid); len(result) != 0 {
+		error := fmt.Sprintf("Expected: no gaps   got: %v", result)
+		t.Error(error)
+	}
+}
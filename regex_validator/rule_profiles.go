@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// names of the rule profiles that can be selected, each one creates a differently configured RegexValidatorGroup
+const (
+	PROFILE_BRIEF_2017         = "brief2017"         // the rules given in the 2017 brief (Part 1), the default
+	PROFILE_ROYAL_MAIL_CURRENT = "royalmail-current" // the rules Royal Mail uses today
+	PROFILE_PERMISSIVE         = "permissive"        // accepts anything with the general shape of a postcode
+
+	// the profiles above that also accept BFPO & overseas territory postcodes, each with its own classification
+	PROFILE_BRIEF_2017_NON_GEOGRAPHIC         = "brief2017-nongeographic"
+	PROFILE_ROYAL_MAIL_CURRENT_NON_GEOGRAPHIC = "royalmail-current-nongeographic"
+)
+
+// type that stores a rule profile: the function that creates its RegexValidatorGroup, the function that works
+// out why it rejected a postcode & whether it accepts the non-geographic postcodes of "createNonGeographicClassRules"
+type RuleProfile struct {
+	create        func() *RegexValidatorGroup
+	failureReason func(postcode string) string
+	nonGeographic bool
+}
+
+// maps the name of each rule profile to its rules
+var ruleProfiles = map[string]RuleProfile{
+	PROFILE_BRIEF_2017:                        {create: createMainRegexValidatorGroup, failureReason: brief2017FailureReason},
+	PROFILE_ROYAL_MAIL_CURRENT:                {create: createRoyalMailRegexValidatorGroup, failureReason: royalMailFailureReason},
+	PROFILE_PERMISSIVE:                        {create: createPermissiveRegexValidatorGroup, failureReason: permissiveFailureReason},
+	PROFILE_BRIEF_2017_NON_GEOGRAPHIC:         {create: createMainRegexValidatorGroup, failureReason: brief2017FailureReason, nonGeographic: true},
+	PROFILE_ROYAL_MAIL_CURRENT_NON_GEOGRAPHIC: {create: createRoyalMailRegexValidatorGroup, failureReason: royalMailFailureReason, nonGeographic: true},
+}
+
+// "ruleProfileNames" returns the names of every rule profile in alphabetical order
+func ruleProfileNames() []string {
+	names := make([]string, 0, len(ruleProfiles))
+	for name := range ruleProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// "createRegexValidatorGroupForProfile" creates the RegexValidatorGroup for the rule profile named "name",
+// an error is returned if there is no profile with that name
+func createRegexValidatorGroupForProfile(name string) (*RegexValidatorGroup, error) {
+	profile, ok := ruleProfiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown rule profile \"%s\", must be one of: %s", name, strings.Join(ruleProfileNames(), ", "))
+	}
+	return profile.create(), nil
+}
+
+// "createClassRulesForProfile" creates the PostcodeClassRules of the rule profile named "name", none if it only
+// accepts geographic postcodes
+func createClassRulesForProfile(name string) []*PostcodeClassRule {
+	if !profileAcceptsNonGeographic(name) {
+		return nil
+	}
+	return createNonGeographicClassRules()
+}
+
+// "profileAcceptsNonGeographic" returns whether the rule profile named "name" accepts non-geographic postcodes,
+// the valid records of such a run have a classification column
+func profileAcceptsNonGeographic(name string) bool {
+	return ruleProfiles[name].nonGeographic
+}
+
+// "createRoyalMailRegexValidatorGroup" creates a RegexValidatorGroup that follows the current Royal Mail rules.
+// Unlike the brief's regex the whole string must match, district 0 is only allowed in the areas that use it and
+// a letter after the district is only allowed in the central London districts that have been divided that way
+func createRoyalMailRegexValidatorGroup() *RegexValidatorGroup {
+	// the main regex (postcodes that match it are valid)
+	var mainRegex = regexp.MustCompile(`^((GIR\s0AA)|((((BL|BS|CM|CR|FY|HA|PR|SL|SS)0)|([A-PR-UWYZ][1-9][0-9]?)|([A-PR-UWYZ][A-HK-Y][1-9][0-9]?)|(EC[1-4][ABEHMNPRVWXY])|(SW1[ABEHMNPRVWXY])|(W1[A-HJKPSTUW])|(WC[12][ABEHMNPRVWXY])|(E1W)|(N1[CP])|(NW1W)|(SE1P))\s[0-9][ABD-HJLNP-UW-Z]{2}))$`)
+	var mainRegexValidator = NewRegexValidator(mainRegex, MATCH_MEANS_VALID)
+
+	// regex that matches areas that only have single digit districts being given two digits (postcodes that match it are invalid)
+	var AA99_exclusionRegex = regexp.MustCompile(`^(BR|FY|HA|HD|HG|HR|HS|HX|JE|LD|SM|SR|WC|WN|ZE)[0-9][0-9]\s`)
+	var AA99_exclusionRegexValidator = NewRegexValidator(AA99_exclusionRegex, MATCH_MEANS_NOT_VALID)
+
+	// regex that matches areas that only have double digit districts being given one digit (postcodes that match it are invalid)
+	var AA9_exclusionRegex = regexp.MustCompile(`^(AB|LL|SO)[0-9]\s`)
+	var AA9_exclusionRegexValidator = NewRegexValidator(AA9_exclusionRegex, MATCH_MEANS_NOT_VALID)
+
+	// regex that matches divided districts written without their letter (postcodes that match it are invalid)
+	var undivided_exclusionRegex = regexp.MustCompile(`^(EC[1-4]|SW1|W1|WC[0-9])\s`)
+	var undivided_exclusionRegexValidator = NewRegexValidator(undivided_exclusionRegex, MATCH_MEANS_NOT_VALID)
+
+	var royalMailRegexValidator = NewRegexValidatorGroup()
+	royalMailRegexValidator.AddRegexValidator(mainRegexValidator)
+	royalMailRegexValidator.AddRegexValidator(AA99_exclusionRegexValidator)
+	royalMailRegexValidator.AddRegexValidator(AA9_exclusionRegexValidator)
+	royalMailRegexValidator.AddRegexValidator(undivided_exclusionRegexValidator)
+
+	return royalMailRegexValidator
+}
+
+// "createPermissiveRegexValidatorGroup" creates a RegexValidatorGroup that accepts any string with the general
+// shape of a postcode (upper or lower case, with or without the space) and does not check which letters are used
+func createPermissiveRegexValidatorGroup() *RegexValidatorGroup {
+	// the shape regex (postcodes that match it are valid)
+	var shapeRegex = regexp.MustCompile(`^(?i)(GIR\s?0AA|[A-Z]{1,2}[0-9][A-Z0-9]?\s?[0-9][A-Z]{2})$`)
+	var shapeRegexValidator = NewRegexValidator(shapeRegex, MATCH_MEANS_VALID)
+
+	var permissiveRegexValidator = NewRegexValidatorGroup()
+	permissiveRegexValidator.AddRegexValidator(shapeRegexValidator)
+
+	return permissiveRegexValidator
+}
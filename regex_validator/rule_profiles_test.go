@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// type that stores a row of a rule profile's conformance table
+type profileConformanceCase struct {
+	postcode string
+	expected bool
+}
+
+// the Part 1 table from the brief, the brief2017 profile must give exactly these results
+var brief2017ConformanceTable = []profileConformanceCase{
+	{"$%± ()()", false},
+	{"XX XXX", false},
+	{"A1 9A", false},
+	{"LS44PL", false},
+	{"Q1A 9AA", false},
+	{"V1A 9AA", false},
+	{"X1A 9BB", false},
+	{"LI10 3QP", false},
+	{"LJ10 3QP", false},
+	{"LZ10 3QP", false},
+	{"A9Q 9AA", false},
+	{"AA9C 9AA", false},
+	{"FY10 4PL", false},
+	{"SO1 4QQ", false},
+	{"EC1A 1BB", true},
+	{"W1A 0AX", true},
+	{"M1 1AE", true},
+	{"B33 8TH", true},
+	{"CR2 6XH", true},
+	{"DN55 1PT", true},
+	{"GIR 0AA", true},
+	{"SO10 9AA", true},
+	{"FY9 9AA", true},
+	{"WC1A 9AA", true},
+}
+
+// the current Royal Mail rules, these add district 0, divided central London districts & full string matching
+var royalMailCurrentConformanceTable = []profileConformanceCase{
+	{"$%± ()()", false},
+	{"XX XXX", false},
+	{"A1 9A", false},
+	{"LS44PL", false},
+	{"Q1A 9AA", false},
+	{"LI10 3QP", false},
+	{"FY10 4PL", false},
+	{"SO1 4QQ", false},
+	{"EC1A 1BB", true},
+	{"W1A 0AX", true},
+	{"M1 1AE", true},
+	{"B33 8TH", true},
+	{"CR2 6XH", true},
+	{"DN55 1PT", true},
+	{"GIR 0AA", true},
+	{"SO10 9AA", true},
+	{"FY9 9AA", true},
+	{"WC1A 9AA", true},
+	{"BS0 1AA", true},
+	{"CR0 2YR", true},
+	{"M0 1AA", false},
+	{"LS0 1AA", false},
+	{"E1W 1AA", true},
+	{"N1C 4AA", true},
+	{"SE1P 5AA", true},
+	{"E2W 1AA", false},
+	{"M1A 1AA", false},
+	{"SW1 1AA", false},
+	{"WC1 1AA", false},
+	{"WC3A 1AA", false},
+	{"EC1A 1BBX", false},
+	{"XEC1A 1BB", false},
+}
+
+// the permissive rules, anything with the shape of a postcode is valid
+var permissiveConformanceTable = []profileConformanceCase{
+	{"$%± ()()", false},
+	{"XX XXX", false},
+	{"A1 9A", false},
+	{"LS44PL", true},
+	{"ls4 4pl", true},
+	{"Q1A 9AA", true},
+	{"LI10 3QP", true},
+	{"FY10 4PL", true},
+	{"SO1 4QQ", true},
+	{"EC1A 1BB", true},
+	{"GIR 0AA", true},
+	{"gir0aa", true},
+}
+
+// the non-geographic postcodes the "-nongeographic" profiles add to their base profile's table
+var nonGeographicConformanceTable = []profileConformanceCase{
+	{"BFPO 1234", true},
+	{"BFPO C/O 123", true},
+	{"BF1 3AA", true},
+	{"FIQQ 1ZZ", true},
+	{"GX11 1AA", true},
+	{"BFPO 12345", false},
+	{"FIQQ 1ZY", false},
+}
+
+// "runProfileConformanceTable" validates each postcode in "table" with the rule profile "name", including any
+// class rules the profile has
+func runProfileConformanceTable(t *testing.T, name string, table []profileConformanceCase) {
+	validator, err := createRegexValidatorGroupForProfile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recVal := NewRecordValidator(validator, nil, nil)
+	recVal.SetRuleProfile(name)
+
+	for _, element := range table {
+		rec := &ImportRecord{postcode: element.postcode}
+		recVal.Validate(rec)
+		result := rec.isValid
+
+		if result != element.expected {
+			error := fmt.Sprintf("Given profile: %s & postcode: %s, Expected: %t   got: %t", name, element.postcode, element.expected, result)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: the results in the Part 1 table
+func Test_RuleProfile__Brief2017(t *testing.T) {
+	runProfileConformanceTable(t, PROFILE_BRIEF_2017, brief2017ConformanceTable)
+}
+
+// expected: the results in the current Royal Mail table
+func Test_RuleProfile__RoyalMailCurrent(t *testing.T) {
+	runProfileConformanceTable(t, PROFILE_ROYAL_MAIL_CURRENT, royalMailCurrentConformanceTable)
+}
+
+// expected: the results in the permissive table
+func Test_RuleProfile__Permissive(t *testing.T) {
+	runProfileConformanceTable(t, PROFILE_PERMISSIVE, permissiveConformanceTable)
+}
+
+// expected: the results in the Part 1 table, with the non-geographic postcodes accepted
+func Test_RuleProfile__Brief2017NonGeographic(t *testing.T) {
+	runProfileConformanceTable(t, PROFILE_BRIEF_2017_NON_GEOGRAPHIC, append(append([]profileConformanceCase{}, brief2017ConformanceTable...), nonGeographicConformanceTable...))
+}
+
+// expected: the results in the current Royal Mail table, with the non-geographic postcodes accepted
+func Test_RuleProfile__RoyalMailCurrentNonGeographic(t *testing.T) {
+	runProfileConformanceTable(t, PROFILE_ROYAL_MAIL_CURRENT_NON_GEOGRAPHIC, append(append([]profileConformanceCase{}, royalMailCurrentConformanceTable...), nonGeographicConformanceTable...))
+}
+
+// expected: an error
+// ask for a rule profile that does not exist
+func Test_createRegexValidatorGroupForProfile__Unknown(t *testing.T) {
+	if _, err := createRegexValidatorGroupForProfile("brief2016"); err == nil {
+		t.Error("Given profile: brief2016, Expected: an error   got: nil")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// the sqlite3 command line tool the SQLite sink writes its database with (the standard library has no SQLite
+// driver), the name of the record sink & the table the records are written to
+const (
+	SQLITE_COMMAND    = "sqlite3"
+	SINK_SQLITE       = "sqlite"
+	SQLITE_TABLE_NAME = "records"
+)
+
+// the columns of the database written with -sqlite, followed by POSTCODE_COMPONENT_COLUMNS (empty for invalid
+// records) & the source file when there is more than one input file
+var SQLITE_COLUMNS = []string{"row_id", "postcode", "normalised_postcode", "valid", "reason"}
+
+// the columns of the database written with -sqlite that are indexed
+var SQLITE_INDEXED_COLUMNS = []string{"row_id", "reason"}
+
+func init() {
+	recordSinks[SINK_SQLITE] = func(name string, opts *ProgramOptions) RecordSink {
+		return NewSqliteSink(opts.outputPath(name+".db"), nil)
+	}
+}
+
+// type of RecordSink that writes records to the table "records" of the SQLite database "path", replacing any
+// database already there. The SQL is piped to the sqlite3 command line tool with the records inserted in
+// transactions of "batchSize" records, indexes are created on "indexes" (the first column if it is nil) once
+// every record has been inserted
+type SqliteSink struct {
+	path       string
+	indexes    []string
+	batchSize  int
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	stderr     bytes.Buffer
+	writer     *bufio.Writer
+	columns    []string
+	numWritten int
+	err        error
+}
+
+// "NewSqliteSink" creates a SqliteSink that writes to the database "path" & indexes "indexes"
+func NewSqliteSink(path string, indexes []string) *SqliteSink {
+	return &SqliteSink{path: path, indexes: indexes, batchSize: BATCH_SIZE_DEFAULT}
+}
+
+// "Open" removes any database at the path, starts sqlite3 & creates the table
+func (sink *SqliteSink) Open(columns []string) error {
+	if err := os.Remove(sink.path); err != nil && !os.IsNotExist(err) {
+		return sink.fail(err)
+	}
+
+	sink.columns = columns
+	if sink.indexes == nil {
+		sink.indexes = columns[:1]
+	}
+
+	sink.cmd = exec.Command(SQLITE_COMMAND, "-bail", sink.path)
+	sink.cmd.Stdout = ioutil.Discard
+	sink.cmd.Stderr = &sink.stderr
+	stdin, err := sink.cmd.StdinPipe()
+	if err != nil {
+		return sink.fail(err)
+	}
+	if err := sink.cmd.Start(); err != nil {
+		sink.cmd = nil
+		return sink.fail(fmt.Errorf("could not run %s, it must be installed to write SQLite databases: %s", SQLITE_COMMAND, err))
+	}
+	sink.stdin = stdin
+	sink.writer = bufio.NewWriter(stdin)
+
+	// the database is only written by this run so it does not need to survive a crash part way through
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = sqliteIdentifier(column)
+	}
+	fmt.Fprintln(sink.writer, "PRAGMA journal_mode = OFF;")
+	fmt.Fprintln(sink.writer, "PRAGMA synchronous = OFF;")
+	fmt.Fprintf(sink.writer, "CREATE TABLE %s (%s);\n", sqliteIdentifier(SQLITE_TABLE_NAME), strings.Join(quoted, ", "))
+	_, err = fmt.Fprintln(sink.writer, "BEGIN;")
+	return sink.fail(err)
+}
+
+// "Write" inserts one record, a transaction is committed after every "batchSize" records
+func (sink *SqliteSink) Write(values []interface{}) error {
+	if sink.err != nil {
+		return sink.err
+	}
+
+	literals := make([]string, len(values))
+	for i, value := range values {
+		literals[i] = sqliteLiteral(value)
+	}
+	fmt.Fprintf(sink.writer, "INSERT INTO %s VALUES (%s);\n", sqliteIdentifier(SQLITE_TABLE_NAME), strings.Join(literals, ", "))
+
+	var err error
+	if sink.numWritten++; sink.numWritten%sink.batchSize == 0 {
+		_, err = fmt.Fprintln(sink.writer, "COMMIT;\nBEGIN;")
+	}
+	return sink.fail(err)
+}
+
+// "Close" commits the last transaction, creates the indexes & waits for sqlite3 to finish
+func (sink *SqliteSink) Close() error {
+	if sink.cmd == nil {
+		return sink.err
+	}
+
+	if sink.err == nil {
+		fmt.Fprintln(sink.writer, "COMMIT;")
+		for _, column := range sink.indexes {
+			fmt.Fprintf(sink.writer, "CREATE INDEX %s ON %s (%s);\n", sqliteIdentifier(SQLITE_TABLE_NAME+"_"+column),
+				sqliteIdentifier(SQLITE_TABLE_NAME), sqliteIdentifier(column))
+		}
+		sink.fail(sink.writer.Flush())
+	}
+	sink.fail(sink.stdin.Close())
+
+	// an error from sqlite3 explains a failed write better than the broken pipe does
+	if err := sink.cmd.Wait(); err != nil {
+		sink.err = nil
+		sink.fail(fmt.Errorf("%s: %s", err, strings.TrimSpace(sink.stderr.String())))
+	}
+	sink.cmd = nil
+	return sink.err
+}
+
+// "fail" keeps the first error the sink has as an OutputError & returns it
+func (sink *SqliteSink) fail(err error) error {
+	if err != nil && sink.err == nil {
+		sink.err = &OutputError{path: sink.path, err: err}
+	}
+	return sink.err
+}
+
+// "sqliteIdentifier" quotes the table or column name "name"
+func sqliteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// "sqliteLiteral" returns "value" as an SQL literal: numbers as they are, true & false as 1 & 0 & text quoted
+func sqliteLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return "NULL"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// "writeSqliteDatabase" writes every record, valid & invalid, to the SQLite database "path" with the columns
+// SQLITE_COLUMNS & POSTCODE_COMPONENT_COLUMNS (and the source file when "withSources" is set), indexed on row id
+// & failure reason
+func writeSqliteDatabase(path string, validRecs, invalidRecs []*ImportRecord, withSources bool) error {
+	columns := append(append([]string{}, SQLITE_COLUMNS...), POSTCODE_COMPONENT_COLUMNS...)
+	if withSources {
+		columns = append(columns, SOURCE_FILE_COLUMN)
+	}
+
+	values := func(rec *ImportRecord) []interface{} {
+		values := []interface{}{rec.rowId, rec.postcode, normalisePostcode(rec.postcode), rec.isValid, rec.reason}
+		for _, component := range rec.components.columns() {
+			values = append(values, component)
+		}
+		if withSources {
+			values = append(values, rec.source.path)
+		}
+		return values
+	}
+
+	sink := NewSqliteSink(path, SQLITE_INDEXED_COLUMNS)
+	recs := append(append(make([]*ImportRecord, 0, len(validRecs)+len(invalidRecs)), validRecs...), invalidRecs...)
+	return writeRecords(sink, columns, recs, values)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// "querySqlite" runs "query" on the database "path" with the sqlite3 command line tool & returns its output, the
+// test is skipped if the tool is not installed
+func querySqlite(t *testing.T, path, query string) string {
+	if _, err := exec.LookPath(SQLITE_COMMAND); err != nil {
+		t.Skip(SQLITE_COMMAND + " is not installed")
+	}
+	out, err := exec.Command(SQLITE_COMMAND, path, query).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// expected: values are written as SQL literals, text is quoted with its quotes doubled
+func Test_sqliteLiteral(t *testing.T) {
+	testCases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{uint64(12), "12"},
+		{"EC1A 1BB", "'EC1A 1BB'"},
+		{"O'Neil", "'O''Neil'"},
+		{true, "1"},
+		{false, "0"},
+		{0.5, "0.5"},
+		{nil, "NULL"},
+	}
+
+	for _, element := range testCases {
+		if result := sqliteLiteral(element.value); result != element.expected {
+			error := fmt.Sprintf("Given value: %#v, Expected: %s   got: %s", element.value, element.expected, result)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: every record, valid & invalid, is in the database with its components & the row id & reason indexed,
+// whatever the size of the transactions
+func Test_writeSqliteDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlite")
+	check(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.db")
+
+	// the database is replaced, not added to
+	check(ioutil.WriteFile(path, []byte("not a database"), 0644))
+
+	components, _ := parsePostcodeComponents("EC1A 1BB")
+	valid := []*ImportRecord{{rowId: 1, postcode: "EC1A 1BB", isValid: true, components: components}}
+	invalid := []*ImportRecord{
+		{rowId: 2, postcode: "ls4 4pl", reason: REASON_LOWER_CASE},
+		{rowId: 3, postcode: "O'NEIL", reason: REASON_JUNK},
+	}
+	if err := writeSqliteDatabase(path, valid, invalid, false); err != nil {
+		if _, lookErr := exec.LookPath(SQLITE_COMMAND); lookErr != nil {
+			t.Skip(SQLITE_COMMAND + " is not installed")
+		}
+		t.Fatal(err)
+	}
+
+	result := querySqlite(t, path, "SELECT row_id, postcode, normalised_postcode, valid, reason, district FROM records ORDER BY row_id;")
+	expected := "1|EC1A 1BB|EC1A1BB|1||EC1A\n2|ls4 4pl|LS44PL|0|lower_case|\n3|O'NEIL|O'NEIL|0|junk|\n"
+	if result != expected {
+		error := fmt.Sprintf("Expected: %q   got: %q", expected, result)
+		t.Error(error)
+	}
+
+	indexes := querySqlite(t, path, "SELECT name FROM sqlite_master WHERE type = 'index' ORDER BY name;")
+	if indexes != "records_reason\nrecords_row_id\n" {
+		error := fmt.Sprintf("Expected: indexes on row_id & reason   got: %q", indexes)
+		t.Error(error)
+	}
+
+	// records are committed in batches, the last one being partly full
+	sink := NewSqliteSink(path, nil)
+	sink.batchSize = 2
+	recs := make([]*ImportRecord, 5)
+	for i := range recs {
+		recs[i] = &ImportRecord{rowId: uint64(i + 1)}
+	}
+	check(writeRecords(sink, []string{"row_id"}, recs, func(rec *ImportRecord) []interface{} { return []interface{}{rec.rowId} }))
+
+	if result := querySqlite(t, path, "SELECT count(*), sum(row_id) FROM records;"); strings.TrimSpace(result) != "5|15" {
+		error := fmt.Sprintf("Given 5 records in batches of 2, Expected: 5|15   got: %q", result)
+		t.Error(error)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// the names of the files statistics are written to
+const (
+	STATISTICS_CSV_FILE_NAME  = "statistics.csv"
+	STATISTICS_JSON_FILE_NAME = "statistics.json"
+)
+
+// the number of most frequent invalid values written when no other number is given
+const STATISTICS_TOP_N_DEFAULT int = 10
+
+// type that stores the number of valid & invalid records counted under a single key
+type StatisticsCount struct {
+	Key     string `json:"key"`
+	Valid   uint64 `json:"valid"`
+	Invalid uint64 `json:"invalid"`
+}
+
+// type that stores counts of records broken down by postcode area, district & failure reason, as well as how
+// often each invalid value was seen. The valid & invalid records are counted by different go routines so
+// each go routine keeps its own ValidationStatistics, these are merged once validation has completed
+type ValidationStatistics struct {
+	areas         map[string]*StatisticsCount
+	districts     map[string]*StatisticsCount
+	reasons       map[string]uint64
+	invalidValues map[string]uint64
+}
+
+// create and return a pointer to a new, empty ValidationStatistics
+func NewValidationStatistics() *ValidationStatistics {
+	return &ValidationStatistics{
+		areas:         make(map[string]*StatisticsCount),
+		districts:     make(map[string]*StatisticsCount),
+		reasons:       make(map[string]uint64),
+		invalidValues: make(map[string]uint64),
+	}
+}
+
+// "Add" counts a record that has already been validated
+func (s *ValidationStatistics) Add(rec *ImportRecord) {
+	area, district := statisticsAreaAndDistrict(rec)
+
+	if rec.isValid {
+		countIn(s.areas, area).Valid++
+		countIn(s.districts, district).Valid++
+		return
+	}
+
+	countIn(s.areas, area).Invalid++
+	countIn(s.districts, district).Invalid++
+	s.reasons[rec.reason]++
+	s.invalidValues[rec.postcode]++
+}
+
+// "Merge" adds every count in "other" to the counts in "s"
+func (s *ValidationStatistics) Merge(other *ValidationStatistics) {
+	for key, count := range other.areas {
+		c := countIn(s.areas, key)
+		c.Valid += count.Valid
+		c.Invalid += count.Invalid
+	}
+	for key, count := range other.districts {
+		c := countIn(s.districts, key)
+		c.Valid += count.Valid
+		c.Invalid += count.Invalid
+	}
+	for key, count := range other.reasons {
+		s.reasons[key] += count
+	}
+	for key, count := range other.invalidValues {
+		s.invalidValues[key] += count
+	}
+}
+
+// type that stores the statistics in the form they are written to the output files
+type StatisticsSummary struct {
+	Areas            []StatisticsCount `json:"areas"`
+	Districts        []StatisticsCount `json:"districts"`
+	Reasons          []StatisticsCount `json:"reasons"`
+	TopInvalidValues []StatisticsCount `json:"top_invalid_values"`
+}
+
+// "Summary" returns the statistics as sorted slices, areas & districts are sorted by their key and
+// reasons & invalid values by how often they were seen. Only the "topN" most frequent invalid values are kept
+func (s *ValidationStatistics) Summary(topN int) StatisticsSummary {
+	summary := StatisticsSummary{
+		Areas:     sortedCounts(s.areas),
+		Districts: sortedCounts(s.districts),
+		Reasons:   mostFrequent(s.reasons, len(s.reasons)),
+	}
+	summary.TopInvalidValues = mostFrequent(s.invalidValues, topN)
+	return summary
+}
+
+// "statisticsAreaAndDistrict" returns the area & district a record is counted under. Valid records use their
+// parsed components, invalid records use the leading letters & the text before the first space so that
+// systematically broken values still group together
+func statisticsAreaAndDistrict(rec *ImportRecord) (string, string) {
+	if rec.isValid && len(rec.components.area) > 0 {
+		return rec.components.area, rec.components.district
+	}
+
+	upper := strings.ToUpper(strings.TrimSpace(rec.postcode))
+	areaLen := strings.IndexFunc(upper, func(r rune) bool { return r < 'A' || r > 'Z' })
+	if areaLen == -1 {
+		areaLen = len(upper)
+	}
+
+	district := upper
+	if idx := strings.IndexAny(upper, " \t"); idx >= 0 {
+		district = upper[:idx]
+	}
+
+	return upper[:areaLen], district
+}
+
+// "countIn" returns the StatisticsCount stored under "key", creating it if it does not exist yet
+func countIn(counts map[string]*StatisticsCount, key string) *StatisticsCount {
+	c, ok := counts[key]
+	if !ok {
+		c = &StatisticsCount{Key: key}
+		counts[key] = c
+	}
+	return c
+}
+
+// "sortedCounts" returns the counts sorted by their key
+func sortedCounts(counts map[string]*StatisticsCount) []StatisticsCount {
+	result := make([]StatisticsCount, 0, len(counts))
+	for _, c := range counts {
+		result = append(result, *c)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// "mostFrequent" returns the "n" keys with the highest count as invalid StatisticsCounts, keys with the same
+// count are sorted by the key so the output is the same every run
+func mostFrequent(counts map[string]uint64, n int) []StatisticsCount {
+	result := make([]StatisticsCount, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, StatisticsCount{Key: key, Invalid: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Invalid != result[j].Invalid {
+			return result[i].Invalid > result[j].Invalid
+		}
+		return result[i].Key < result[j].Key
+	})
+
+	if n >= 0 && n < len(result) {
+		result = result[:n]
+	}
+	return result
+}
+
+// "writeStatisticsFiles" writes the summary to a csv file at "csvPath" & a json file at "jsonPath". The csv
+// file has a row for each count, the "section" column says which part of the summary the row belongs to
+func writeStatisticsFiles(csvPath, jsonPath string, summary StatisticsSummary) error {
+	csvFile, err := os.Create(csvPath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(csvFile)
+	fmt.Fprintln(writer, "section,key,valid,invalid")
+
+	sections := []struct {
+		name   string
+		counts []StatisticsCount
+	}{
+		{"area", summary.Areas},
+		{"district", summary.Districts},
+		{"reason", summary.Reasons},
+		{"top_invalid_value", summary.TopInvalidValues},
+	}
+	for _, section := range sections {
+		for _, c := range section.counts {
+			fmt.Fprintf(writer, "%s,%s,%d,%d\n", section.name, csvField(c.Key), c.Valid, c.Invalid)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		csvFile.Close()
+		return err
+	}
+	if err := csvFile.Close(); err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(jsonPath, append(jsonBytes, '\n'), 0666)
+}
+
+// "csvField" quotes "str" if it has a comma, quote or newline in it so it can be written as a single csv field
+func csvField(str string) string {
+	if !strings.ContainsAny(str, ",\"\r\n") {
+		return str
+	}
+	return `"` + strings.Replace(str, `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// "newValidatedTestRecord" creates an ImportRecord & validates it with the brief2017 rules
+func newValidatedTestRecord(postcode string) *ImportRecord {
+	rec := &ImportRecord{postcode: postcode}
+	NewRecordValidator(createMainRegexValidatorGroup(), nil, nil).Validate(rec)
+	return rec
+}
+
+// expected: counts by area, district, reason & the most frequent invalid values, the same whether the records
+// are counted together or by two ValidationStatistics that are then merged
+func Test_ValidationStatistics__Summary(t *testing.T) {
+
+	postcodes := []string{"SW1W 0NY", "SW1A 1AA", "SW1A 2AA", "LS44PL", "LS44PL", "FY10 4PL", "M1 1AE"}
+
+	together := NewValidationStatistics()
+	first, second := NewValidationStatistics(), NewValidationStatistics()
+	for i, postcode := range postcodes {
+		rec := newValidatedTestRecord(postcode)
+		together.Add(rec)
+		if i%2 == 0 {
+			first.Add(rec)
+		} else {
+			second.Add(rec)
+		}
+	}
+	first.Merge(second)
+
+	expected := StatisticsSummary{
+		Areas:            []StatisticsCount{{"FY", 0, 1}, {"LS", 0, 2}, {"M", 1, 0}, {"SW", 3, 0}},
+		Districts:        []StatisticsCount{{"FY10", 0, 1}, {"LS44PL", 0, 2}, {"M1", 1, 0}, {"SW1A", 2, 0}, {"SW1W", 1, 0}},
+		Reasons:          []StatisticsCount{{REASON_NO_SPACE, 0, 2}, {REASON_SINGLE_DIGIT_AREA, 0, 1}},
+		TopInvalidValues: []StatisticsCount{{"LS44PL", 0, 2}},
+	}
+
+	for name, stats := range map[string]*ValidationStatistics{"together": together, "merged": first} {
+		result := stats.Summary(1)
+
+		if !reflect.DeepEqual(result, expected) {
+			error := fmt.Sprintf("Counted: %s, Expected: %v   got: %v", name, expected, result)
+			t.Error(error)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"sort"
+)
+
+// names of the extra columns written to "failed_validation.csv" when suggestions are requested
+var SUGGESTION_COLUMNS = []string{"suggestion", "confidence"}
+
+// the number of edits (confusions or transpositions) that may be combined to build a single candidate
+const SUGGESTION_MAX_EDITS int = 2
+
+// the cost of each kind of edit in percent, the confidence of a suggestion is 100% minus the total cost of its edits
+const (
+	COST_NORMALISE     int = 5  // changing the case, spacing or adding the missing space
+	COST_CONFUSION     int = 25 // swapping a character for one it is commonly confused with (OCR/keyboard)
+	COST_TRANSPOSITION int = 35 // swapping two neighbouring characters
+	COST_UNKNOWN       int = 30 // added when a directory is used and the candidate is not in it
+	COST_TERMINATED    int = 15 // added when a directory is used and the candidate has been terminated
+	COST_MAX           int = 100
+)
+
+// characters that are commonly mistaken for one another, each pair is used in both directions
+var confusablePairs = [][2]byte{
+	{'O', '0'}, {'D', '0'}, {'Q', '0'}, {'I', '1'}, {'L', '1'}, {'S', '5'},
+	{'Z', '2'}, {'B', '8'}, {'G', '6'}, {'T', '7'}, {'A', '4'},
+}
+
+// type that builds corrected postcodes for invalid ones, a candidate is only suggested if the
+// RegexValidatorGroup finds it valid. If a PostcodeDirectory is given candidates that exist are preferred
+type SuggestionEngine struct {
+	validator  *RegexValidatorGroup
+	directory  *PostcodeDirectory
+	confusions map[byte][]byte
+}
+
+// create and return a pointer to a new SuggestionEngine, "dir" may be nil
+func NewSuggestionEngine(val *RegexValidatorGroup, dir *PostcodeDirectory) *SuggestionEngine {
+	confusions := make(map[byte][]byte)
+	for _, pair := range confusablePairs {
+		confusions[pair[0]] = append(confusions[pair[0]], pair[1])
+		confusions[pair[1]] = append(confusions[pair[1]], pair[0])
+	}
+
+	return &SuggestionEngine{validator: val, directory: dir, confusions: confusions}
+}
+
+// "Suggest" returns the best correction for "postcode" and a confidence score between 0 & 1. The boolean
+// returned is false if no valid candidate could be found. When several candidates share the best cost the
+// confidence is divided between them, as there is no way to know which one was meant
+func (s *SuggestionEngine) Suggest(postcode string) (string, float64, bool) {
+
+	// remove all whitespace and upper-case the postcode, the space is put back before the inward code later
+	compact := normalisePostcode(postcode)
+	if len(compact) < 5 || len(compact) > 8 {
+		return "", 0, false
+	}
+
+	baseCost := 0
+	if withInwardSpace(compact) != postcode {
+		baseCost = COST_NORMALISE
+	}
+
+	// build every candidate reachable within SUGGESTION_MAX_EDITS edits, keeping the cheapest cost of each
+	costs := map[string]int{compact: baseCost}
+	frontier := []string{compact}
+
+	for edits := 0; edits < SUGGESTION_MAX_EDITS; edits++ {
+		var next []string
+		for _, cand := range frontier {
+			for _, edit := range s.singleEdits(cand) {
+				cost := costs[cand] + edit.cost
+				if prev, seen := costs[edit.value]; !seen || cost < prev {
+					costs[edit.value] = cost
+					next = append(next, edit.value)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	// validate each candidate and keep those with the lowest cost
+	bestCost := COST_MAX
+	var best []string
+
+	for cand, cost := range costs {
+		spaced := withInwardSpace(cand)
+		if !s.validator.GroupIsStringValid(spaced) {
+			continue
+		}
+
+		if s.directory != nil {
+			switch status, _ := s.directory.Lookup(spaced); status {
+			case DIRECTORY_UNKNOWN:
+				cost += COST_UNKNOWN
+			case DIRECTORY_TERMINATED:
+				cost += COST_TERMINATED
+			}
+		}
+
+		if cost < bestCost {
+			bestCost = cost
+			best = []string{spaced}
+		} else if cost == bestCost {
+			best = append(best, spaced)
+		}
+	}
+
+	if len(best) == 0 {
+		return "", 0, false
+	}
+
+	// pick the same suggestion every run when more than one candidate is equally good
+	sort.Strings(best)
+	confidence := float64(COST_MAX-bestCost) / float64(COST_MAX*len(best))
+
+	return best[0], confidence, true
+}
+
+// type that stores a candidate made by a single edit & the cost of that edit
+type suggestionEdit struct {
+	value string
+	cost  int
+}
+
+// "withInwardSpace" puts a single space before the last 3 characters (the inward code) of a compact postcode
+func withInwardSpace(compact string) string {
+	return compact[:len(compact)-3] + " " + compact[len(compact)-3:]
+}
+
+// "singleEdits" returns every candidate that is a single confusion or transposition away from "compact"
+func (s *SuggestionEngine) singleEdits(compact string) []suggestionEdit {
+	var edits []suggestionEdit
+	b := []byte(compact)
+
+	for i := range b {
+		orig := b[i]
+		for _, repl := range s.confusions[orig] {
+			b[i] = repl
+			edits = append(edits, suggestionEdit{string(b), COST_CONFUSION})
+		}
+		b[i] = orig
+	}
+
+	for i := 0; i < len(b)-1; i++ {
+		if b[i] == b[i+1] {
+			continue
+		}
+		b[i], b[i+1] = b[i+1], b[i]
+		edits = append(edits, suggestionEdit{string(b), COST_TRANSPOSITION})
+		b[i], b[i+1] = b[i+1], b[i]
+	}
+
+	return edits
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// expected: each invalid postcode is corrected to the valid postcode it was most likely meant to be
+// call Suggest with postcodes that are one or two keystrokes away from being valid
+func Test_SuggestionEngine_Suggest__Corrections(t *testing.T) {
+
+	engine := NewSuggestionEngine(createMainRegexValidatorGroup(), nil)
+
+	testCases := []struct {
+		postcode string
+		expected string
+	}{
+		{"LS44PL", "LS4 4PL"},
+		{"ls4 4pl", "LS4 4PL"},
+		{"SW1W ONY", "SW1W 0NY"},
+		{"EC1A IBB", "EC1A 1BB"},
+		{"B33 T8H", "B33 8TH"},
+		{"B33  8TH", "B33 8TH"},
+	}
+
+	for _, element := range testCases {
+		result, confidence, ok := engine.Suggest(element.postcode)
+
+		if !ok || result != element.expected || confidence <= 0 || confidence > 1 {
+			error := fmt.Sprintf("Given postcode: %s, Expected: %s   got: %s (confidence: %.2f, ok: %t)", element.postcode, element.expected, result, confidence, ok)
+			t.Error(error)
+		}
+	}
+}
+
+// expected: a fix that only needs the space adding is more confident than one needing a confusion
+// compare the confidence of two suggestions
+func Test_SuggestionEngine_Suggest__ConfidenceOrdering(t *testing.T) {
+
+	engine := NewSuggestionEngine(createMainRegexValidatorGroup(), nil)
+
+	_, spaceConfidence, _ := engine.Suggest("LS44PL")
+	_, confusionConfidence, _ := engine.Suggest("SW1W ONY")
+
+	if spaceConfidence <= confusionConfidence {
+		error := fmt.Sprintf("Expected: %.2f > %.2f", spaceConfidence, confusionConfidence)
+		t.Error(error)
+	}
+}
+
+// expected: no suggestion
+// call Suggest with postcodes that are too far from being valid
+func Test_SuggestionEngine_Suggest__NoSuggestion(t *testing.T) {
+
+	engine := NewSuggestionEngine(createMainRegexValidatorGroup(), nil)
+
+	for _, postcode := range []string{"$%± ()()", "XX XXX", "A1 9A"} {
+		if result, _, ok := engine.Suggest(postcode); ok {
+			error := fmt.Sprintf("Given postcode: %s, Expected: no suggestion   got: %s", postcode, result)
+			t.Error(error)
+		}
+	}
+}